@@ -23,11 +23,11 @@ func (ArgLong) isArg()  {}
 func (ArgValue) isArg() {}
 
 func (a ArgShort) Unexpected() Error {
-	return &ErrorUnexpectedOption{string(a.A)}
+	return &ErrorUnexpectedOption{A: "-" + string(a.A)}
 }
 func (a ArgLong) Unexpected() Error {
-	return &ErrorUnexpectedOption{a.A}
+	return &ErrorUnexpectedOption{A: "--" + a.A}
 }
 func (a ArgValue) Unexpected() Error {
-	return &ErrorUnexpectedArgument{a.A}
+	return &ErrorUnexpectedArgument{A: a.A}
 }
@@ -0,0 +1,237 @@
+package lexopt
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Parse the command line into the fields of a struct.
+//
+// v must be a pointer to a struct. Fields are bound with a lexopt tag that
+// lists the option's names and properties, separated by commas:
+//
+//	type args struct {
+//	    Number  uint32   `lexopt:"-n,--number,value=NUM"`
+//	    Shout   bool     `lexopt:"--shout"`
+//	    Include []string `lexopt:"-I,--include,value=DIR"`
+//	    Output  *string  `lexopt:"-o,--output,value=FILE"`
+//	    Thing   string   `lexopt:"positional,value=THING"`
+//	    Rest    []string `lexopt:"positional,value=FILE"`
+//	}
+//
+// The properties are:
+//
+//   - -x and --long: the option's short and long names.
+//   - positional: the field takes a positional argument instead. Positional
+//     fields are filled in order, and a slice takes all that are left.
+//   - value=NAME: a placeholder for the value, for help text.
+//
+// A bool or *bool field is a flag and is set to true when the option is
+// given. Any other field takes a value, parsed as by ParseValue(). Slice
+// fields collect every value of a repeated option, pointer fields are
+// only allocated if the option is given, and fields whose pointer
+// implements encoding.TextUnmarshaler or flag.Value use that. Fields
+// without a tag are left alone, as are options that aren't given, so set
+// defaults before calling Bind().
+//
+// Bind panics if v is not a pointer to a struct or a tag is invalid.
+//
+// # Errors
+//
+// The same errors a hand-written loop would return: ErrorUnexpectedOption
+// for unknown options, ErrorUnexpectedArgument for extra positional
+// arguments, ErrorMissingValue and ErrorParsingFailed for values, and
+// anything Next() returns.
+//
+// # Example
+//
+//	cfg := args{Number: 1}
+//	if err := lexopt.Bind(lexopt.ParserFromEnv(), &cfg); err != nil {
+//	    log.Fatal(err)
+//	}
+func Bind(p *Parser, v any) Error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("lexopt: Bind needs a pointer to a struct, not %T", v))
+	}
+	fields := bindFields(rv.Elem().Type())
+	target := rv.Elem()
+
+	var positionals []bindField
+	for _, field := range fields {
+		if field.Positional {
+			positionals = append(positionals, field)
+		}
+	}
+
+	for {
+		arg, ok, err := p.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		var field *bindField
+		if short, ok := arg.(ArgShort); ok {
+			field = findField(fields, func(f *bindField) bool { return slices.Contains(f.Shorts, short.A) })
+		} else if long, ok := arg.(ArgLong); ok {
+			field = findField(fields, func(f *bindField) bool { return slices.Contains(f.Longs, long.A) })
+		} else if value, ok := arg.(ArgValue); ok {
+			if len(positionals) == 0 {
+				return p.Unexpected(arg)
+			}
+			fv := target.FieldByIndex(positionals[0].index)
+			if err := bindValue(fv, value.A); err != nil {
+				span := p.Span()
+				return &ErrorParsingFailed{Value: value.A, Error2: err, Span: &span}
+			}
+			if fv.Kind() != reflect.Slice || isScalar(fv.Type()) {
+				positionals = positionals[1:]
+			}
+			continue
+		}
+		if field == nil {
+			return p.Unexpected(arg, bindNames(fields)...)
+		}
+		fv := target.FieldByIndex(field.index)
+		if field.flag {
+			if fv.Kind() == reflect.Pointer {
+				fv.Set(reflect.New(fv.Type().Elem()))
+				fv = fv.Elem()
+			}
+			fv.SetBool(true)
+			continue
+		}
+		value, err := p.Value()
+		if err != nil {
+			return err
+		}
+		if err := bindValue(fv, value); err != nil {
+			return p.ParsingFailed(value, err)
+		}
+	}
+	return nil
+}
+
+// The contents of a lexopt struct tag, as used by Bind().
+type BindTag struct {
+	Shorts []rune
+	Longs  []string
+	// Whether the field takes positional arguments.
+	Positional bool
+	// The value placeholder, from value=NAME.
+	ValueName string
+}
+
+// A struct field with a lexopt tag.
+type bindField struct {
+	BindTag
+	index []int
+	// Whether the field is a flag (a bool or *bool).
+	flag bool
+}
+
+// Read the lexopt tags of a struct type.
+func bindFields(t reflect.Type) []bindField {
+	var fields []bindField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("lexopt")
+		if !ok || tag == "-" {
+			continue
+		}
+		bindTag, err := ParseBindTag(tag)
+		if err != nil {
+			panic(fmt.Sprintf("lexopt: field %v: %v", sf.Name, err))
+		}
+		elem := sf.Type
+		if elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		fields = append(fields, bindField{
+			BindTag: bindTag,
+			index:   sf.Index,
+			flag:    !bindTag.Positional && elem.Kind() == reflect.Bool && !isScalar(sf.Type),
+		})
+	}
+	return fields
+}
+
+// Parse the contents of a lexopt struct tag, like "-n,--number,value=NUM".
+//
+// This is for tools that work with the same tags as Bind(), like code
+// generators.
+func ParseBindTag(tag string) (BindTag, error) {
+	var bindTag BindTag
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "--") && len(part) > 2 {
+			bindTag.Longs = append(bindTag.Longs, part[2:])
+		} else if strings.HasPrefix(part, "-") && utf8.RuneCountInString(part) == 2 {
+			bindTag.Shorts = append(bindTag.Shorts, []rune(part)[1])
+		} else if part == "positional" {
+			bindTag.Positional = true
+		} else if name, ok := strings.CutPrefix(part, "value="); ok {
+			bindTag.ValueName = name
+		} else {
+			return BindTag{}, fmt.Errorf("invalid lexopt tag %#v: unknown part %#v", tag, part)
+		}
+	}
+	if bindTag.Positional && (bindTag.Shorts != nil || bindTag.Longs != nil) {
+		return BindTag{}, fmt.Errorf("invalid lexopt tag %#v: positional fields can't have option names", tag)
+	}
+	if !bindTag.Positional && bindTag.Shorts == nil && bindTag.Longs == nil {
+		return BindTag{}, fmt.Errorf("invalid lexopt tag %#v: no option names", tag)
+	}
+	return bindTag, nil
+}
+
+// Parse a value into a field, appending if it's a slice.
+func bindValue(fv reflect.Value, value string) error {
+	if fv.Kind() != reflect.Slice || isScalar(fv.Type()) {
+		return parseInto(fv, value)
+	}
+	elem := reflect.New(fv.Type().Elem()).Elem()
+	if err := parseInto(elem, value); err != nil {
+		return err
+	}
+	fv.Set(reflect.Append(fv, elem))
+	return nil
+}
+
+var (
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	flagValueType       = reflect.TypeFor[flag.Value]()
+)
+
+// Whether a type parses a whole value by itself, even if it's a slice.
+func isScalar(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	return pt.Implements(textUnmarshalerType) || pt.Implements(flagValueType)
+}
+
+// The spellings of the options, for suggestions.
+func bindNames(fields []bindField) []string {
+	var names []string
+	for _, field := range fields {
+		for _, long := range field.Longs {
+			names = append(names, "--"+long)
+		}
+	}
+	return names
+}
+
+func findField(fields []bindField, match func(*bindField) bool) *bindField {
+	for i := range fields {
+		if !fields[i].Positional && match(&fields[i]) {
+			return &fields[i]
+		}
+	}
+	return nil
+}
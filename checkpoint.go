@@ -0,0 +1,61 @@
+package lexopt
+
+// A saved position of a Parser, from parser.Checkpoint().
+//
+// It's opaque and only meaningful to the parser it came from (or clones
+// of that parser).
+type Checkpoint struct {
+	source struct {
+		slice   []string
+		index   int
+		args    []string
+		origins []argOrigin
+	}
+	state      state
+	lastOption lastOption
+	span       Span
+	completion *Completion
+}
+
+// Save the current position so it can be returned to with parser.Restore().
+//
+// This is cheap: nothing is copied but a few words of state.
+//
+// # Example
+// Try to parse an argument speculatively and back out if it doesn't fit.
+//
+//	checkpoint := parser.Checkpoint()
+//	arg, ok, err := parser.Next()
+//	if err != nil || !ok || !looksLikeWhatWeWant(arg) {
+//	    parser.Restore(checkpoint)
+//	}
+func (p *Parser) Checkpoint() Checkpoint {
+	return Checkpoint{
+		source:     p.source,
+		state:      p.state,
+		lastOption: p.lastOption,
+		span:       p.span,
+		completion: p.completion,
+	}
+}
+
+// Go back to a position saved with parser.Checkpoint().
+//
+// Everything that was consumed since then will be returned again. A
+// checkpoint can be restored any number of times.
+func (p *Parser) Restore(checkpoint Checkpoint) {
+	p.source = checkpoint.source
+	p.state = checkpoint.state
+	p.lastOption = checkpoint.lastOption
+	p.span = checkpoint.span
+	p.completion = checkpoint.completion
+}
+
+// Create an independent copy of the parser at its current position.
+//
+// Advancing the clone doesn't affect the original, and vice versa. The
+// arguments themselves are shared, so this is cheap.
+func (p *Parser) Clone() *Parser {
+	clone := *p
+	return &clone
+}
@@ -0,0 +1,404 @@
+/*
+Lexoptgen generates a parse loop for a struct with lexopt tags.
+
+It reads the same tags as lexopt.Bind(), but instead of using reflection
+at runtime it writes out the plain parser.Next()/parser.Value() loop you
+would write by hand. Add a directive next to the struct:
+
+	//go:generate go run github.com/jcbhmr/go-lexopt/cmd/lexoptgen -type=args
+
+	type args struct {
+	    Number uint32 `lexopt:"-n,--number,value=NUM"`
+	    Shout  bool   `lexopt:"--shout"`
+	    Thing  string `lexopt:"positional,value=THING"`
+	}
+
+and go generate writes args_lexopt.go with a method
+
+	func (a *args) parse(parser *lexopt.Parser) lexopt.Error
+
+Usage:
+
+	lexoptgen -type=NAME [-method=NAME] [-output=FILE] [DIR]
+
+DIR defaults to the current directory. Fields are handled the same way as
+by Bind(): bool fields are flags, slices collect repeated values, pointers
+are set only if the option is given, and other types are parsed with
+strconv, time.ParseDuration, UnmarshalText or Set, whichever applies.
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jcbhmr/go-lexopt"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("lexoptgen: ")
+
+	typeName := flag.String("type", "", "the struct type to generate a parse method for")
+	method := flag.String("method", "parse", "the name of the generated method")
+	output := flag.String("output", "", "the output file (default TYPE_lexopt.go in DIR)")
+	flag.Parse()
+	if *typeName == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	if *output == "" {
+		*output = filepath.Join(dir, strings.ToLower(*typeName)+"_lexopt.go")
+	}
+
+	src, err := generate(dir, *typeName, *method, filepath.Base(*output))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, src, 0o666); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Generate the source of the parse method for typeName in the package in dir.
+func generate(dir string, typeName string, method string, outputName string) ([]byte, error) {
+	fset := token.NewFileSet()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if strings.HasSuffix(name, "_test.go") || name == outputName {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %v", dir)
+	}
+
+	config := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		// Errors elsewhere in the package don't matter as long as the
+		// struct's fields can be resolved, which is checked below.
+		Error: func(error) {},
+	}
+	pkg, _ := config.Check(files[0].Name.Name, fset, files, nil)
+
+	obj := pkg.Scope().Lookup(typeName)
+	if obj == nil {
+		return nil, fmt.Errorf("type %v not found in %v", typeName, dir)
+	}
+	st, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a struct type", typeName)
+	}
+
+	g := &generator{
+		pkg:     pkg,
+		imports: map[string]bool{"github.com/jcbhmr/go-lexopt": true},
+	}
+	if err := g.parseMethod(typeName, method, st); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by lexoptgen -type=%v; DO NOT EDIT.\n\n", typeName)
+	fmt.Fprintf(&out, "package %v\n\n", pkg.Name())
+	// Standard library imports go first, like goimports does.
+	var std, other []string
+	for _, path := range slices.Sorted(maps.Keys(g.imports)) {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	fmt.Fprintf(&out, "import (\n")
+	for _, path := range std {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	if len(std) > 0 {
+		fmt.Fprintf(&out, "\n")
+	}
+	for _, path := range other {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	fmt.Fprintf(&out, ")\n\n")
+	out.Write(g.buf.Bytes())
+	return format.Source(out.Bytes())
+}
+
+type generator struct {
+	pkg     *types.Package
+	imports map[string]bool
+	buf     bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// A struct field with a lexopt tag.
+type field struct {
+	name string
+	typ  types.Type
+	lexopt.BindTag
+}
+
+func (g *generator) parseMethod(typeName string, method string, st *types.Struct) error {
+	var options, positionals []field
+	for i := 0; i < st.NumFields(); i++ {
+		v := st.Field(i)
+		tag, ok := reflect.StructTag(st.Tag(i)).Lookup("lexopt")
+		if !ok || tag == "-" {
+			continue
+		}
+		bindTag, err := lexopt.ParseBindTag(tag)
+		if err != nil {
+			return fmt.Errorf("field %v: %v", v.Name(), err)
+		}
+		if v.Type() == types.Typ[types.Invalid] {
+			return fmt.Errorf("field %v: cannot resolve its type", v.Name())
+		}
+		f := field{v.Name(), v.Type(), bindTag}
+		if f.Positional {
+			positionals = append(positionals, f)
+		} else {
+			options = append(options, f)
+		}
+	}
+
+	recv := string(unicode.ToLower([]rune(typeName)[0]))
+	g.printf("// Parse the command line into the fields of %v.\n", recv)
+	g.printf("//\n// Generated from the lexopt tags on %v.\n", typeName)
+	g.printf("func (%v *%v) %v(parser *lexopt.Parser) lexopt.Error {\n", recv, typeName, method)
+	if len(positionals) > 0 {
+		g.printf("positional := 0\n")
+	}
+	g.printf("for {\n")
+	g.printf("arg, ok, err := parser.Next()\n")
+	g.printf("if err != nil {\nreturn err\n}\n")
+	g.printf("if !ok {\nbreak\n}\n")
+
+	keyword := "if"
+	for _, f := range options {
+		var conds []string
+		for _, short := range f.Shorts {
+			conds = append(conds, fmt.Sprintf("(arg == lexopt.ArgShort{A: %v})", strconv.QuoteRune(short)))
+		}
+		for _, long := range f.Longs {
+			conds = append(conds, fmt.Sprintf("(arg == lexopt.ArgLong{A: %v})", strconv.Quote(long)))
+		}
+		g.printf("%v %v {\n", keyword, strings.Join(conds, " || "))
+		keyword = "} else if"
+		target := recv + "." + f.name
+		if isFlag(f.typ) {
+			if _, ok := f.typ.(*types.Pointer); ok {
+				g.printf("parsed := true\n%v = &parsed\n", target)
+			} else {
+				g.printf("%v = true\n", target)
+			}
+			continue
+		}
+		g.printf("value, err := parser.Value()\n")
+		g.printf("if err != nil {\nreturn err\n}\n")
+		if err := g.assign(target, f.typ, func(err string) string {
+			return fmt.Sprintf("return parser.ParsingFailed(value, %v)", err)
+		}); err != nil {
+			return fmt.Errorf("field %v: %v", f.name, err)
+		}
+	}
+	for i, f := range positionals {
+		multiple := isMultiple(f.typ)
+		if multiple {
+			g.printf("%v v, ok := arg.(lexopt.ArgValue); ok && positional >= %v {\n", keyword, i)
+		} else {
+			g.printf("%v v, ok := arg.(lexopt.ArgValue); ok && positional == %v {\n", keyword, i)
+		}
+		keyword = "} else if"
+		g.printf("value := v.A\n")
+		if err := g.assign(recv+"."+f.name, f.typ, func(err string) string {
+			// Nothing has been consumed since the argument, so the span is
+			// still its span.
+			return fmt.Sprintf("span := parser.Span()\nreturn &lexopt.ErrorParsingFailed{Value: value, Error2: %v, Span: &span}", err)
+		}); err != nil {
+			return fmt.Errorf("field %v: %v", f.name, err)
+		}
+		if !multiple {
+			g.printf("positional++\n")
+		}
+	}
+	// Known long options, for suggestions.
+	unexpected := "parser.Unexpected(arg"
+	for _, f := range options {
+		for _, long := range f.Longs {
+			unexpected += ", " + strconv.Quote("--"+long)
+		}
+	}
+	unexpected += ")"
+	if keyword == "if" {
+		g.printf("return %v\n", unexpected)
+	} else {
+		g.printf("} else {\nreturn %v\n}\n", unexpected)
+	}
+	g.printf("}\n")
+	g.printf("return nil\n")
+	g.printf("}\n")
+	return nil
+}
+
+// Generate code that parses value and stores it in target, which has type t.
+// fail gives the statements that return the error in the Go expression err.
+func (g *generator) assign(target string, t types.Type, fail func(err string) string) error {
+	if isScalar(t) {
+		return g.convert(target, t, fail)
+	}
+	if ptr, ok := t.(*types.Pointer); ok {
+		if err := g.declare(ptr.Elem(), fail); err != nil {
+			return err
+		}
+		g.printf("%v = &parsed\n", target)
+		return nil
+	}
+	if slice, ok := t.Underlying().(*types.Slice); ok {
+		if err := g.declare(slice.Elem(), fail); err != nil {
+			return err
+		}
+		g.printf("%v = append(%v, parsed)\n", target, target)
+		return nil
+	}
+	return g.convert(target, t, fail)
+}
+
+// Generate code that declares parsed as a t parsed from value.
+func (g *generator) declare(t types.Type, fail func(err string) string) error {
+	if t == types.Typ[types.String] {
+		g.printf("parsed := value\n")
+		return nil
+	}
+	g.printf("var parsed %v\n", types.TypeString(t, g.qualifier))
+	return g.convert("parsed", t, fail)
+}
+
+// Generate code that parses value into target, an existing variable of type t.
+func (g *generator) convert(target string, t types.Type, fail func(err string) string) error {
+	typeName := types.TypeString(t, g.qualifier)
+	if hasMethod(t, "UnmarshalText") {
+		g.printf("if err := %v.UnmarshalText([]byte(value)); err != nil {\n%v\n}\n", target, fail("err"))
+		return nil
+	}
+	if hasMethod(t, "Set") && hasMethod(t, "String") {
+		g.printf("if err := %v.Set(value); err != nil {\n%v\n}\n", target, fail("err"))
+		return nil
+	}
+	if named, ok := t.(*types.Named); ok && named.Obj().Pkg() != nil &&
+		named.Obj().Pkg().Path() == "time" && named.Obj().Name() == "Duration" {
+		g.imports["time"] = true
+		g.printf("raw, err2 := time.ParseDuration(value)\n")
+		g.printf("if err2 != nil {\n%v\n}\n", fail("err2"))
+		g.printf("%v = raw\n", target)
+		return nil
+	}
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return fmt.Errorf("unsupported type %v", typeName)
+	}
+	var parse string
+	info := basic.Info()
+	sizes := types.SizesFor("gc", "amd64")
+	bits := sizes.Sizeof(basic) * 8
+	switch {
+	case basic.Kind() == types.String && t == types.Typ[types.String]:
+		g.printf("%v = value\n", target)
+		return nil
+	case basic.Kind() == types.String:
+		g.printf("%v = %v(value)\n", target, typeName)
+		return nil
+	case basic.Kind() == types.Bool:
+		parse = "strconv.ParseBool(value)"
+	case basic.Kind() == types.Int || basic.Kind() == types.Uint || basic.Kind() == types.Uintptr:
+		// Their size depends on the platform.
+		if info&types.IsUnsigned != 0 {
+			parse = "strconv.ParseUint(value, 10, strconv.IntSize)"
+		} else {
+			parse = "strconv.ParseInt(value, 10, strconv.IntSize)"
+		}
+	case info&types.IsInteger != 0 && info&types.IsUnsigned != 0:
+		parse = fmt.Sprintf("strconv.ParseUint(value, 10, %v)", bits)
+	case info&types.IsInteger != 0:
+		parse = fmt.Sprintf("strconv.ParseInt(value, 10, %v)", bits)
+	case info&types.IsFloat != 0:
+		parse = fmt.Sprintf("strconv.ParseFloat(value, %v)", bits)
+	default:
+		return fmt.Errorf("unsupported type %v", typeName)
+	}
+	g.imports["strconv"] = true
+	g.printf("raw, err2 := %v\n", parse)
+	g.printf("if err2 != nil {\n%v\n}\n", fail("err2.(*strconv.NumError).Err"))
+	g.printf("%v = %v(raw)\n", target, typeName)
+	return nil
+}
+
+func (g *generator) qualifier(pkg *types.Package) string {
+	if pkg == g.pkg {
+		return ""
+	}
+	g.imports[pkg.Path()] = true
+	return pkg.Name()
+}
+
+// Whether a field is a flag: a bool or *bool that doesn't parse itself.
+func isFlag(t types.Type) bool {
+	if isScalar(t) {
+		return false
+	}
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Kind() == types.Bool
+}
+
+// Whether a positional field takes all remaining arguments.
+func isMultiple(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Slice)
+	return ok && !isScalar(t)
+}
+
+// Whether a type parses a whole value by itself, even if it's a slice.
+func isScalar(t types.Type) bool {
+	return hasMethod(t, "UnmarshalText") || (hasMethod(t, "Set") && hasMethod(t, "String"))
+}
+
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), true, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
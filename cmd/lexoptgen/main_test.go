@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the generated files in testdata")
+
+func TestGenerate(t *testing.T) {
+	src, err := generate("testdata/hello", "args", "parse", "args_lexopt.go")
+	require.Nil(t, err)
+	path := "testdata/hello/args_lexopt.go"
+	if *update {
+		require.Nil(t, os.WriteFile(path, src, 0o666))
+	}
+	want, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Equal(t, string(want), string(src))
+}
+
+func TestGeneratedProgram(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a program")
+	}
+	bin := filepath.Join(t.TempDir(), "hello")
+	build, err := exec.Command("go", "build", "-o", bin, "./testdata/hello").CombinedOutput()
+	require.Nil(t, err, string(build))
+	// The program exits with 1 after printing an error, and that's the
+	// only failure expected.
+	run := func(wantStatus int, args ...string) string {
+		cmd := exec.Command(bin, args...)
+		cmd.Args[0] = "hello"
+		out, err := cmd.Output()
+		if wantStatus == 0 {
+			require.Nil(t, err)
+		} else {
+			var exitErr *exec.ExitError
+			require.ErrorAs(t, err, &exitErr)
+			require.Equal(t, wantStatus, exitErr.ExitCode())
+		}
+		return string(out)
+	}
+	require.Equal(t,
+		"HELLO WORLD! 2 true [a b] out 1s 2 0.5 [1 2]\n",
+		run(0, "-n2", "--shout", "-q", "-Ia", "-I", "b", "-o", "out", "--delay", "1s", "--level=high", "--ratio", ".5", "world", "1", "2"))
+	require.Equal(t, "error: invalid value 'x' for '-n': invalid syntax\n  hello -n x\n           ^\n", run(1, "-n", "x"))
+	require.Equal(t, "error: invalid value 'loud' for '--level': unknown level \"loud\"\n  hello --level loud\n                ^^^^\n", run(1, "--level", "loud"))
+	require.Equal(t, "error: invalid option '--bogus'\n  hello --bogus\n        ^^^^^^^\n", run(1, "--bogus"))
+	// Positional arguments point at themselves too, as with Bind().
+	require.Equal(t, "error: cannot parse argument \"x\": invalid syntax\n  hello world x\n              ^\n", run(1, "world", "x"))
+}
+
+func TestGenerateErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(src string) {
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0o666))
+	}
+
+	write("package main\n\ntype args struct {\n\tC chan int `lexopt:\"--c\"`\n}\n")
+	_, err := generate(dir, "args", "parse", "args_lexopt.go")
+	require.EqualError(t, err, "field C: unsupported type chan int")
+
+	write("package main\n\ntype args struct {\n\tC int `lexopt:\"c\"`\n}\n")
+	_, err = generate(dir, "args", "parse", "args_lexopt.go")
+	require.EqualError(t, err, `field C: invalid lexopt tag "c": unknown part "c"`)
+
+	_, err = generate(dir, "other", "parse", "other_lexopt.go")
+	require.ErrorContains(t, err, "type other not found")
+}
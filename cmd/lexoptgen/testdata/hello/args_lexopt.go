@@ -0,0 +1,101 @@
+// Code generated by lexoptgen -type=args; DO NOT EDIT.
+
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/jcbhmr/go-lexopt"
+)
+
+// Parse the command line into the fields of a.
+//
+// Generated from the lexopt tags on args.
+func (a *args) parse(parser *lexopt.Parser) lexopt.Error {
+	positional := 0
+	for {
+		arg, ok, err := parser.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if (arg == lexopt.ArgShort{A: 'n'}) || (arg == lexopt.ArgLong{A: "number"}) {
+			value, err := parser.Value()
+			if err != nil {
+				return err
+			}
+			raw, err2 := strconv.ParseUint(value, 10, 32)
+			if err2 != nil {
+				return parser.ParsingFailed(value, err2.(*strconv.NumError).Err)
+			}
+			a.Number = uint32(raw)
+		} else if (arg == lexopt.ArgLong{A: "shout"}) {
+			a.Shout = true
+		} else if (arg == lexopt.ArgShort{A: 'q'}) || (arg == lexopt.ArgLong{A: "quiet"}) {
+			parsed := true
+			a.Quiet = &parsed
+		} else if (arg == lexopt.ArgShort{A: 'I'}) || (arg == lexopt.ArgLong{A: "include"}) {
+			value, err := parser.Value()
+			if err != nil {
+				return err
+			}
+			parsed := value
+			a.Include = append(a.Include, parsed)
+		} else if (arg == lexopt.ArgShort{A: 'o'}) || (arg == lexopt.ArgLong{A: "output"}) {
+			value, err := parser.Value()
+			if err != nil {
+				return err
+			}
+			parsed := value
+			a.Output = &parsed
+		} else if (arg == lexopt.ArgLong{A: "delay"}) {
+			value, err := parser.Value()
+			if err != nil {
+				return err
+			}
+			raw, err2 := time.ParseDuration(value)
+			if err2 != nil {
+				return parser.ParsingFailed(value, err2)
+			}
+			a.Delay = raw
+		} else if (arg == lexopt.ArgLong{A: "level"}) {
+			value, err := parser.Value()
+			if err != nil {
+				return err
+			}
+			if err := a.Level.UnmarshalText([]byte(value)); err != nil {
+				return parser.ParsingFailed(value, err)
+			}
+		} else if (arg == lexopt.ArgLong{A: "ratio"}) {
+			value, err := parser.Value()
+			if err != nil {
+				return err
+			}
+			raw, err2 := strconv.ParseFloat(value, 32)
+			if err2 != nil {
+				return parser.ParsingFailed(value, err2.(*strconv.NumError).Err)
+			}
+			a.Ratio = float32(raw)
+		} else if v, ok := arg.(lexopt.ArgValue); ok && positional == 0 {
+			value := v.A
+			a.Thing = value
+			positional++
+		} else if v, ok := arg.(lexopt.ArgValue); ok && positional >= 1 {
+			value := v.A
+			var parsed int
+			raw, err2 := strconv.ParseInt(value, 10, strconv.IntSize)
+			if err2 != nil {
+				span := parser.Span()
+				return &lexopt.ErrorParsingFailed{Value: value, Error2: err2.(*strconv.NumError).Err, Span: &span}
+			}
+			parsed = int(raw)
+			a.Rest = append(a.Rest, parsed)
+		} else {
+			return parser.Unexpected(arg, "--number", "--shout", "--quiet", "--include", "--output", "--delay", "--level", "--ratio")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jcbhmr/go-lexopt"
+)
+
+//go:generate go run github.com/jcbhmr/go-lexopt/cmd/lexoptgen -type=args
+
+type args struct {
+	Number  uint32        `lexopt:"-n,--number,value=NUM"`
+	Shout   bool          `lexopt:"--shout"`
+	Quiet   *bool         `lexopt:"-q,--quiet"`
+	Include []string      `lexopt:"-I,--include,value=DIR"`
+	Output  *string       `lexopt:"-o,--output,value=FILE"`
+	Delay   time.Duration `lexopt:"--delay"`
+	Level   level         `lexopt:"--level"`
+	Ratio   float32       `lexopt:"--ratio"`
+	Thing   string        `lexopt:"positional,value=THING"`
+	Rest    []int         `lexopt:"positional,value=N"`
+	ignored int
+}
+
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level %q", text)
+	}
+	return nil
+}
+
+func main() {
+	a := args{Number: 1}
+	parser := lexopt.ParserFromEnv()
+	if err := a.parse(parser); err != nil {
+		fmt.Print(parser.RenderError(err, false))
+		os.Exit(1)
+	}
+	message := fmt.Sprintf("Hello %s!", a.Thing)
+	if a.Shout {
+		message = strings.ToUpper(message)
+	}
+	fmt.Println(message, a.Number, a.Quiet != nil, a.Include, *a.Output, a.Delay, a.Level, a.Ratio, a.Rest)
+}
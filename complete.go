@@ -0,0 +1,247 @@
+package lexopt
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The argument that asks a program for completions, as in
+// prog __complete CWORD WORD...
+//
+// It's hidden from users: a completion script generated for the program
+// calls it with the words on the command line being edited. See
+// CompletionRequest().
+const CompleteCommand = "__complete"
+
+// What sort of word is being completed.
+type CompletionKind uint8
+
+const (
+	// The cursor wasn't reached, for example because parsing stopped
+	// early with an error.
+	CompleteNothing CompletionKind = iota
+	// An option, like --ver or -. The prefix includes the dashes.
+	CompleteOption
+	// More short options in a cluster, as in -ab. Candidates are appended
+	// to Before, so they should be single characters without a dash.
+	CompleteShort
+	// The value of an option, in a Value() or Values() slot. This is
+	// either a separate word, as in --color ne, or attached, as in
+	// --color=ne and -cne.
+	CompleteValue
+	// A positional argument.
+	CompleteArgument
+)
+
+// Where the cursor is on a partial command line, from
+// parser.Completion().
+type Completion struct {
+	Kind CompletionKind
+	// The option the value belongs to, like --color or -c, if Kind is
+	// CompleteValue.
+	Option string
+	// The part of the word before what's being completed, like --color=
+	// or -ab. The shell replaces the whole word, so it's put in front of
+	// every candidate.
+	Before string
+	// What the user typed of the thing being completed. Candidates that
+	// don't start with it are left out.
+	Prefix string
+	// Whether the word comes after --, so it can't be an option.
+	AfterDashes bool
+}
+
+// A possible completion.
+type Candidate struct {
+	Value string
+	// A short description to show next to the value. It may be empty.
+	Description string
+}
+
+// Create a parser for the words on a partial command line.
+//
+// words must start with the binary name, like os.Args. cword is the index
+// of the word the cursor is in, and that word should end at the cursor.
+// Words after it are ignored.
+//
+// Run the usual parsing loop with the parser. When it reaches the word
+// under the cursor Next() returns (nil, false, nil), and Value() and
+// Values() return ErrorMissingValue, so the loop ends the way it does at
+// the end of a command line. parser.Completion() then tells what sort of
+// word it was. Errors are to be expected on partial command lines, so it's
+// usually best to ignore them and ask for the completion anyway.
+//
+// # Example
+//
+//	parser := lexopt.ParserFromCompletion([]string{"cargo", "--color", "ne"}, 2)
+//	// ... the usual loop, which calls parser.Value() after --color ...
+//	completion, _ := parser.Completion()
+//	// completion is {Kind: CompleteValue, Option: "--color", Prefix: "ne"}
+func ParserFromCompletion(words []string, cword int) *Parser {
+	if cword < 1 || cword >= len(words) {
+		panic(fmt.Sprintf("lexopt: cursor %v out of range for %v words", cword, len(words)))
+	}
+	binName := words[0]
+	p := newParser(&binName, struct {
+		slice   []string
+		index   int
+		args    []string
+		origins []argOrigin
+	}{slice: words[1 : cword+1]})
+	p.completing = true
+	p.cursor = cword - 1
+	return p
+}
+
+// Check whether the command line asks for completions, as in
+// prog __complete CWORD WORD..., and return the words and cursor index
+// for ParserFromCompletion().
+//
+// args is the whole command line, like os.Args. The words are the command
+// line being edited, including its binary name, and CWORD is the index of
+// the word under the cursor.
+//
+// # Example
+//
+//	if words, cword, ok := lexopt.CompletionRequest(os.Args); ok {
+//	    parser := lexopt.ParserFromCompletion(words, cword)
+//	    // ...
+//	    lexopt.WriteCandidates(os.Stdout, completion, candidates)
+//	    return
+//	}
+func CompletionRequest(args []string) (words []string, cword int, ok bool) {
+	if len(args) < 4 || args[1] != CompleteCommand {
+		return nil, 0, false
+	}
+	cword, err := strconv.Atoi(args[2])
+	words = args[3:]
+	if err != nil || cword < 1 || cword >= len(words) {
+		return nil, 0, false
+	}
+	return words, cword, true
+}
+
+// What sort of word the cursor is in.
+//
+// ok is false if the parser doesn't come from ParserFromCompletion(), or
+// if parsing hasn't reached the cursor (yet).
+func (p *Parser) Completion() (Completion, bool) {
+	if p.completion == nil {
+		return Completion{}, false
+	}
+	return *p.completion, true
+}
+
+// Record what's being completed, and consume the rest of the command line.
+func (p *Parser) complete(c Completion) {
+	p.completion = &c
+	p.state = stateNone{}
+	p.source.index = len(p.source.slice)
+}
+
+// If the parser is completing and is about to read the word under the
+// cursor, record it as an option or positional argument.
+//
+// This is called by Next() with a new word in stateNone or
+// stateFinishedOpts.
+func (p *Parser) completeWord() bool {
+	if !p.completing || p.source.index != p.cursor {
+		return false
+	}
+	word := p.source.slice[p.source.index]
+	if _, ok := p.state.(stateFinishedOpts); ok {
+		p.complete(Completion{Kind: CompleteArgument, Prefix: word, AfterDashes: true})
+	} else if strings.HasPrefix(word, "--") && strings.Contains(word, "=") {
+		// Let the caller see the option and ask for its value.
+		return false
+	} else if strings.HasPrefix(word, "--") || word == "-" {
+		p.complete(Completion{Kind: CompleteOption, Prefix: word})
+	} else if strings.HasPrefix(word, "-") {
+		// Let the caller see the options in the cluster.
+		return false
+	} else {
+		p.complete(Completion{Kind: CompleteArgument, Prefix: word})
+	}
+	return true
+}
+
+// If the parser is completing and has just finished the cluster of short
+// options under the cursor, record that.
+func (p *Parser) completeShorts(arg []byte) bool {
+	if !p.completing || p.source.index-1 != p.cursor {
+		return false
+	}
+	p.complete(Completion{Kind: CompleteShort, Before: string(arg)})
+	return true
+}
+
+// If the parser is completing and a value would come from the word under
+// the cursor, record that.
+//
+// This is called by Value() and Values() before they take a value, and by
+// OptionalValue(), which only takes an attached one, with detached false.
+func (p *Parser) completeValue(detached bool) bool {
+	if !p.completing {
+		return false
+	}
+	option, _ := p.formatLastOption()
+	if pending, ok := p.state.(statePendingValue); ok && p.source.index-1 == p.cursor {
+		word := p.source.slice[p.cursor]
+		p.complete(Completion{
+			Kind:   CompleteValue,
+			Option: option,
+			Before: word[:len(word)-len(pending.a)],
+			Prefix: pending.a,
+		})
+	} else if shorts, ok := p.state.(stateShorts); ok && p.source.index-1 == p.cursor {
+		pos := shorts.b
+		if pos < uint(len(shorts.a)) && shorts.a[pos] == '=' {
+			pos++
+		}
+		p.complete(Completion{
+			Kind:   CompleteValue,
+			Option: option,
+			Before: string(shorts.a[:pos]),
+			Prefix: string(shorts.a[pos:]),
+		})
+	} else if detached && !p.hasPending() && p.source.index == p.cursor {
+		p.complete(Completion{
+			Kind:   CompleteValue,
+			Option: option,
+			Prefix: p.source.slice[p.cursor],
+		})
+	} else {
+		return false
+	}
+	return true
+}
+
+// Write candidates in the format completion scripts read.
+//
+// Candidates that don't start with c.Prefix are left out, and c.Before
+// is put in front of the rest. Each one is written on its own line, with
+// its description after a tab if it has one:
+//
+//	--color=never	Never use colors
+//	--color=auto
+//
+// Runs of whitespace in descriptions, including tabs and newlines, are
+// replaced with single spaces. Candidates whose values contain tabs or
+// newlines are left out.
+func WriteCandidates(w io.Writer, c Completion, candidates []Candidate) error {
+	var b strings.Builder
+	for _, candidate := range candidates {
+		if !strings.HasPrefix(candidate.Value, c.Prefix) || strings.ContainsAny(candidate.Value, "\t\n") {
+			continue
+		}
+		b.WriteString(c.Before + candidate.Value)
+		if description := strings.Join(strings.Fields(candidate.Description), " "); description != "" {
+			b.WriteString("\t" + description)
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
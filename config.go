@@ -0,0 +1,188 @@
+package lexopt
+
+import (
+	"os"
+	"slices"
+	"strings"
+)
+
+// What a registered option expects after it.
+type OptionKind uint8
+
+const (
+	// The option never takes a value.
+	OptionFlag OptionKind = iota
+	// The option always takes a value, either attached (-ovalue, -o=value,
+	// --option=value) or as the next argument (-o value, --option value).
+	OptionValue
+	// The option may take a value, but only an attached one.
+	OptionOptionalValue
+)
+
+// Settings for a Parser, applied with parser.Configure().
+//
+// The zero value keeps the parser schema-less: every option is returned
+// and it's up to the caller to know which ones take values.
+type ParserConfig struct {
+	// The short options the parser knows about.
+	//
+	// If Shorts or Longs is set the parser checks every option against
+	// them. Next() returns ErrorUnexpectedOption for options that aren't
+	// registered and ErrorMissingValue for an OptionValue option that has
+	// no value left, and the rest of -ofile is never split into more short
+	// options if o takes a value.
+	//
+	// The caller still has to call Value() or OptionalValue() to get the
+	// value.
+	Shorts map[rune]OptionKind
+	// The long options the parser knows about, without the leading dashes.
+	Longs map[string]OptionKind
+	// Accept any unambiguous prefix of a long option in Longs, the way GNU
+	// getopt_long() does: --verb is returned as ArgLong{"verbose"}, as long
+	// as no other option starts with verb. An exact match always wins, so
+	// --color still works next to --colors.
+	//
+	// A prefix of several options is an ErrorAmbiguousOption.
+	Abbreviations bool
+	// Replace @path arguments with the arguments in the file at path, like
+	// gcc and javac do. This is how to get past limits on the length of a
+	// command line.
+	//
+	// Arguments in the file are separated by whitespace and can be quoted
+	// with single or double quotes, with backslash escapes outside single
+	// quotes. # starts a comment. Response files can name other response
+	// files, up to 16 deep, but not themselves. Paths are relative to the
+	// working directory.
+	//
+	// Only arguments that Next() reads are expanded, so the value of an
+	// option and anything after -- are left alone, as is everything after
+	// a -- in a response file. A file that can't be read or parsed is an
+	// ErrorResponseFile.
+	//
+	// Spans still count the arguments of the command line: an argument
+	// from a file gets the span of the @path argument, with the file and
+	// line in Span.File and Span.Line.
+	ResponseFiles bool
+	// End options at the first positional argument, the way POSIX getopt()
+	// does, so everything after it is an ArgValue as if it came after --.
+	// This is for commands that run other commands, like env or xargs,
+	// where in `env -i ls -l` the -l belongs to ls. Use PosixlyCorrect()
+	// to turn it on when GNU tools would.
+	StopAtFirstPositional bool
+}
+
+// Report whether the POSIXLY_CORRECT environment variable is set, which is
+// how GNU getopt() is told to stop at the first positional argument.
+//
+// # Example
+//
+//	parser := lexopt.ParserFromEnv().Configure(lexopt.ParserConfig{
+//	    StopAtFirstPositional: lexopt.PosixlyCorrect(),
+//	})
+func PosixlyCorrect() bool {
+	_, ok := os.LookupEnv("POSIXLY_CORRECT")
+	return ok
+}
+
+// Apply a configuration to the parser.
+//
+// This should be done before parsing starts. It returns p so it can be
+// chained onto a constructor.
+//
+// # Example
+//
+//	parser := lexopt.ParserFromEnv().Configure(lexopt.ParserConfig{
+//	    Shorts: map[rune]lexopt.OptionKind{'o': lexopt.OptionValue, 'v': lexopt.OptionFlag},
+//	    Longs:  map[string]lexopt.OptionKind{"output": lexopt.OptionValue, "verbose": lexopt.OptionFlag},
+//	})
+func (p *Parser) Configure(config ParserConfig) *Parser {
+	p.config = config
+	return p
+}
+
+// Look up an option in the registry. ok is false if there's no registry.
+func (p *Parser) lookupOption(arg Arg) (kind OptionKind, known bool, ok bool) {
+	if p.config.Shorts == nil && p.config.Longs == nil {
+		return 0, false, false
+	}
+	if short, isShort := arg.(ArgShort); isShort {
+		kind, known = p.config.Shorts[short.A]
+	} else if long, isLong := arg.(ArgLong); isLong {
+		kind, known = p.config.Longs[long.A]
+	} else {
+		panic("unreachable")
+	}
+	return kind, known, true
+}
+
+// Whether the last option is registered as taking a value.
+func (p *Parser) lastOptionTakesValue() bool {
+	var kind OptionKind
+	var known bool
+	if short, ok := p.lastOption.(lastOptionShort); ok {
+		kind, known = p.config.Shorts[short.A]
+	} else if long, ok := p.lastOption.(lastOptionLong); ok {
+		kind, known = p.config.Longs[long.A[2:]]
+	}
+	return known && kind != OptionFlag
+}
+
+// Check an option that's about to be returned against the registry.
+func (p *Parser) checkOption(arg Arg) (Arg, bool, Error) {
+	kind, known, ok := p.lookupOption(arg)
+	if !ok {
+		return arg, true, nil
+	}
+	if long, isLong := arg.(ArgLong); isLong && !known && p.config.Abbreviations {
+		candidates := p.abbreviations(long.A)
+		if len(candidates) == 1 {
+			arg = p.setLong("--" + candidates[0])
+			kind, known = p.config.Longs[candidates[0]]
+		} else if len(candidates) > 1 {
+			if _, ok := p.state.(statePendingValue); ok {
+				p.state = stateNone{}
+			}
+			span := p.span
+			for i := range candidates {
+				candidates[i] = "--" + candidates[i]
+			}
+			return nil, false, &ErrorAmbiguousOption{A: "--" + long.A, Candidates: candidates, Span: &span}
+		}
+	}
+	if !known {
+		// Drop --unknown=value, it's not worth a second error.
+		if _, ok := p.state.(statePendingValue); ok {
+			p.state = stateNone{}
+		}
+		return nil, false, p.Unexpected(arg, p.knownLongs()...)
+	}
+	if kind == OptionValue && !p.hasPending() && p.source.index >= len(p.source.slice) {
+		return nil, false, p.missingValue()
+	}
+	return arg, true, nil
+}
+
+// The registered long options that start with prefix, sorted.
+func (p *Parser) abbreviations(prefix string) []string {
+	if prefix == "" {
+		return nil
+	}
+	var candidates []string
+	for long := range p.config.Longs {
+		if strings.HasPrefix(long, prefix) {
+			candidates = append(candidates, long)
+		}
+	}
+	slices.Sort(candidates)
+	return candidates
+}
+
+// The registered long options, with their dashes, for suggestions.
+func (p *Parser) knownLongs() []string {
+	longs := make([]string, 0, len(p.config.Longs))
+	for long := range p.config.Longs {
+		longs = append(longs, "--"+long)
+	}
+	slices.Sort(longs)
+	return longs
+}
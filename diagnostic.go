@@ -0,0 +1,216 @@
+package lexopt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	ansiError = "\x1b[1;31m"
+	ansiReset = "\x1b[0m"
+)
+
+// Render an error as a multi-line diagnostic that points at the command line.
+//
+// The command line is printed after BinName() with the part the error is
+// about underlined:
+//
+//	error: invalid value 'abc' for '--jobs': invalid syntax
+//	  myapp --jobs=abc
+//	               ^^^
+//
+// The location comes from the error's Span. Errors without one, like those
+// from arg.Unexpected(), use parser.Span() instead, so call this right
+// after the failing Next() or Value(). Errors that aren't an Error are
+// rendered as just the message, and so is an ErrorParsingFailed without a
+// Span, which is about a value that isn't on the command line, like one
+// from an environment variable.
+//
+// If the error is about an argument from a response file, the @path
+// argument is underlined and a last line names the file and the line:
+//
+//	error: invalid option '--bogus'
+//	  myapp @args.txt
+//	        ^^^^^^^^^
+//	  in response file 'args.txt', line 2
+//
+// Arguments that need it are shell-quoted. If color is true the message
+// and the underline are highlighted with ANSI escapes; use ColorEnabled()
+// to decide.
+//
+// # Example
+//
+//	arg, ok, err := parser.Next()
+//	if err != nil {
+//	    fmt.Fprint(os.Stderr, parser.RenderError(err, lexopt.ColorEnabled(os.Stderr)))
+//	    os.Exit(2)
+//	}
+func (p *Parser) RenderError(err error, color bool) string {
+	var b strings.Builder
+	if color {
+		b.WriteString(ansiError + "error:" + ansiReset + " ")
+	} else {
+		b.WriteString("error: ")
+	}
+	b.WriteString(err.Error())
+	b.WriteString("\n")
+
+	span, ok := errorSpan(err)
+	if !ok {
+		var e Error
+		var parsingFailed *ErrorParsingFailed
+		if !errors.As(err, &e) || errors.As(err, &parsingFailed) {
+			return b.String()
+		}
+		span = p.span
+	}
+	// The command line as it was typed, before response files.
+	args := p.source.slice
+	if p.source.origins != nil {
+		args = p.source.args
+	}
+	// A subcommand's parser only shows its own part of the command line.
+	base := p.argIndex(p.base)
+	if span.Index < base || span.Index >= len(args) {
+		return b.String()
+	}
+
+	var line strings.Builder
+	if p.binNameParts != nil {
+		for i, part := range p.binNameParts {
+			if i > 0 {
+				line.WriteString(" ")
+			}
+			line.WriteString(quoteArg(part))
+		}
+	} else if binName, ok := p.BinName(); ok {
+		line.WriteString(quoteArg(binName))
+	}
+	var start, end int
+	for i := base; i < len(args); i++ {
+		arg := args[i]
+		if line.Len() > 0 {
+			line.WriteString(" ")
+		}
+		if i != span.Index {
+			line.WriteString(quoteArg(arg))
+			continue
+		}
+		quoted, offsets := quoteArgOffsets(arg)
+		// The quotes belong to the underline if the span reaches them.
+		offsets[0] = 0
+		offsets[len(arg)] = len(quoted)
+		startByte := line.Len() + offsets[min(span.Start, len(arg))]
+		endByte := line.Len() + offsets[min(span.End, len(arg))]
+		line.WriteString(quoted)
+		start = utf8.RuneCountInString(line.String()[:startByte])
+		end = utf8.RuneCountInString(line.String()[:endByte])
+	}
+	// Empty spans, as in --jobs=, still get a caret.
+	width := max(end-start, 1)
+
+	b.WriteString("  ")
+	b.WriteString(line.String())
+	b.WriteString("\n  ")
+	b.WriteString(strings.Repeat(" ", start))
+	if color {
+		b.WriteString(ansiError)
+	}
+	b.WriteString(strings.Repeat("^", width))
+	if color {
+		b.WriteString(ansiReset)
+	}
+	b.WriteString("\n")
+	if span.File != "" {
+		fmt.Fprintf(&b, "  in response file '%v', line %v\n", span.File, span.Line)
+	}
+	return b.String()
+}
+
+// Report whether diagnostics written to f should use color.
+//
+// This is false if the NO_COLOR environment variable is set to anything,
+// if TERM is "dumb", or if f is not a terminal.
+func ColorEnabled(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func errorSpan(err error) (Span, bool) {
+	var span *Span
+	var missingValue *ErrorMissingValue
+	var unexpectedOption *ErrorUnexpectedOption
+	var unexpectedArgument *ErrorUnexpectedArgument
+	var unexpectedValue *ErrorUnexpectedValue
+	var parsingFailed *ErrorParsingFailed
+	var nonUnicodeValue *ErrorNonUnicodeValue
+	var unknownSubcommand *ErrorUnknownSubcommand
+	var ambiguousOption *ErrorAmbiguousOption
+	var responseFile *ErrorResponseFile
+	if errors.As(err, &missingValue) {
+		span = missingValue.Span
+	} else if errors.As(err, &unexpectedOption) {
+		span = unexpectedOption.Span
+	} else if errors.As(err, &unexpectedArgument) {
+		span = unexpectedArgument.Span
+	} else if errors.As(err, &unexpectedValue) {
+		span = unexpectedValue.Span
+	} else if errors.As(err, &parsingFailed) {
+		span = parsingFailed.Span
+	} else if errors.As(err, &nonUnicodeValue) {
+		span = nonUnicodeValue.Span
+	} else if errors.As(err, &unknownSubcommand) {
+		span = unknownSubcommand.Span
+	} else if errors.As(err, &ambiguousOption) {
+		span = ambiguousOption.Span
+	} else if errors.As(err, &responseFile) {
+		span = responseFile.Span
+	}
+	if span == nil {
+		return Span{}, false
+	}
+	return *span, true
+}
+
+func quoteArg(arg string) string {
+	quoted, _ := quoteArgOffsets(arg)
+	return quoted
+}
+
+// Quote an argument for a POSIX shell if it needs it. offsets[i] is the
+// position of arg[i] in the result, with one extra entry for len(arg).
+func quoteArgOffsets(arg string) (string, []int) {
+	offsets := make([]int, len(arg)+1)
+	needsQuotes := arg == "" || strings.ContainsFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			strings.ContainsRune("-_=+./:,@%^", r) || r >= utf8.RuneSelf)
+	})
+	if !needsQuotes {
+		for i := range offsets {
+			offsets[i] = i
+		}
+		return arg, offsets
+	}
+	var b strings.Builder
+	b.WriteString("'")
+	for i := 0; i < len(arg); i++ {
+		offsets[i] = b.Len()
+		if arg[i] == '\'' {
+			b.WriteString(`'\''`)
+		} else {
+			b.WriteByte(arg[i])
+		}
+	}
+	offsets[len(arg)] = b.Len()
+	b.WriteString("'")
+	return b.String(), offsets
+}
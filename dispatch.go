@@ -0,0 +1,159 @@
+package lexopt
+
+import "strings"
+
+// Routes subcommands to their handlers.
+//
+// Register each subcommand with Handle(), then call Dispatch() when the
+// parsing loop finds a positional argument that should be a subcommand.
+// The handler gets a parser of its own for the rest of the command line,
+// whose BinName() includes the subcommand, like "cargo install". Handlers
+// can use a Dispatcher of their own for nested subcommands.
+//
+// The zero value is ready to use.
+//
+// # Example
+//
+//	var commands lexopt.Dispatcher
+//	commands.Handle("install", install, "i")
+//	commands.Handle("uninstall", uninstall)
+//	for {
+//	    arg, ok, err := parser.Next()
+//	    // ...
+//	    if value, ok := arg.(lexopt.ArgValue); ok {
+//	        return commands.Dispatch(parser, value.A)
+//	    }
+//	}
+type Dispatcher struct {
+	commands []dispatcherCommand
+}
+
+type dispatcherCommand struct {
+	name    string
+	aliases []string
+	handler func(*Parser) Error
+}
+
+// Register a subcommand, with any number of other names for it.
+//
+// Registering a name twice panics.
+func (d *Dispatcher) Handle(name string, handler func(*Parser) Error, aliases ...string) {
+	for _, n := range append([]string{name}, aliases...) {
+		if d.lookup(n) != nil {
+			panic("lexopt: subcommand " + n + " registered twice")
+		}
+	}
+	d.commands = append(d.commands, dispatcherCommand{name, aliases, handler})
+}
+
+// The names of the registered subcommands, in the order they were
+// registered. Aliases aren't included.
+func (d *Dispatcher) Names() []string {
+	names := make([]string, len(d.commands))
+	for i, command := range d.commands {
+		names[i] = command.name
+	}
+	return names
+}
+
+// Look up a subcommand by name or alias, and return its name.
+func (d *Dispatcher) Lookup(name string) (string, bool) {
+	if command := d.lookup(name); command != nil {
+		return command.name, true
+	}
+	return "", false
+}
+
+func (d *Dispatcher) lookup(name string) *dispatcherCommand {
+	for i := range d.commands {
+		if d.commands[i].name == name {
+			return &d.commands[i]
+		}
+		for _, alias := range d.commands[i].aliases {
+			if alias == name {
+				return &d.commands[i]
+			}
+		}
+	}
+	return nil
+}
+
+// Run the handler for a subcommand.
+//
+// name is usually the ArgValue that Next() just returned. The handler
+// gets p.Subparser() named after the subcommand (not the alias that was
+// used). Once it returns p continues after whatever the handler consumed,
+// and its error is returned as-is. If the handler stopped inside a -abc
+// cluster or after --, p carries on from there too, and if it found a
+// completion request, p.Completion() reports it.
+//
+// # Errors
+//
+// If there's no such subcommand ErrorUnknownSubcommand is returned, with
+// parser.Span() as its span and the subcommands name might be a
+// misspelling of as its Suggestions.
+func (d *Dispatcher) Dispatch(p *Parser, name string) Error {
+	command := d.lookup(name)
+	if command == nil {
+		span := p.span
+		var names []string
+		for _, command := range d.commands {
+			names = append(names, command.name)
+			names = append(names, command.aliases...)
+		}
+		return &ErrorUnknownSubcommand{A: name, Span: &span, Suggestions: Suggest(name, names)}
+	}
+	child := p.Subparser(command.name)
+	err := command.handler(child)
+	// Only where the child got to. Its last option and span are its own.
+	p.source = child.source
+	p.state = child.state
+	p.completion = child.completion
+	return err
+}
+
+// Create a parser for a subcommand, over the rest of the command line.
+//
+// Its BinName() is p's followed by name, like "cargo install". It keeps
+// p's Abbreviations, ResponseFiles and StopAtFirstPositional settings but
+// not its Shorts and Longs, which are p's own options. Configure() replaces
+// all of them, so a subcommand that registers options should pass those
+// settings on too.
+//
+// The child shares the arguments with p but moves through them
+// independently. Spans still count arguments from the start of the whole
+// command line, so errors from either parser can be rendered with either
+// parser's RenderError(), although the child only shows its own part of
+// the command line.
+//
+// This should be called when p is between arguments, as it is right after
+// Next() returns an ArgValue. If p is past --, so is the child, but the
+// first positional argument with ParserConfig.StopAtFirstPositional only
+// ends p's options, so the child has options of its own until its own
+// first positional argument.
+func (p *Parser) Subparser(name string) *Parser {
+	var parts []string
+	if p.binNameParts != nil {
+		parts = append(parts, p.binNameParts...)
+	} else if binName, ok := p.BinName(); ok {
+		parts = append(parts, binName)
+	}
+	parts = append(parts, name)
+	binName := strings.Join(parts, " ")
+	child := newParser(&binName, p.source)
+	child.binNameParts = parts
+	child.config = ParserConfig{
+		Abbreviations:         p.config.Abbreviations,
+		ResponseFiles:         p.config.ResponseFiles,
+		StopAtFirstPositional: p.config.StopAtFirstPositional,
+	}
+	if finished, ok := p.state.(stateFinishedOpts); ok && !finished.atPositional {
+		child.state = stateFinishedOpts{}
+	}
+	child.span = p.span
+	child.base = p.source.index
+	child.completing = p.completing
+	child.cursor = p.cursor
+	child.completion = p.completion
+	return child
+}
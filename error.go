@@ -11,30 +11,85 @@ type Error interface {
 	error
 	Unwrap() error
 }
+
+// Errors created by the parser record where on the command line they
+// happened in a Span field. It's nil for errors created some other way,
+// such as by arg.Unexpected().
+
 type ErrorMissingValue struct {
 	Option *string
+	// The span of the option.
+	Span *Span
 }
 type ErrorUnexpectedOption struct {
-	A string
+	// The option with its dashes, like --verbsoe or -x.
+	A    string
+	Span *Span
+	// Known options that A might be a misspelling of, best first.
+	Suggestions []string
 }
 type ErrorUnexpectedArgument struct {
-	A string
+	A    string
+	Span *Span
 }
 type ErrorUnexpectedValue struct {
 	Option string
 	Value  string
+	// The span of the value.
+	Span *Span
 }
 type ErrorParsingFailed struct {
-	Value string
+	// The option the value belongs to, if known.
+	Option *string
+	// The index of the argument Option was found in, not counting the
+	// binary name. Only meaningful if Option is set, and -1 if the value
+	// didn't come from the command line.
+	Index  int
+	Value  string
 	Error2 error
+	// The span of the value.
+	Span *Span
 }
 type ErrorNonUnicodeValue struct {
-	A string
+	A    string
+	Span *Span
 }
 type ErrorCustom struct {
 	A error
 }
 
+// A positional argument that should have been a subcommand, from
+// dispatcher.Dispatch().
+type ErrorUnknownSubcommand struct {
+	A    string
+	Span *Span
+	// Subcommands that A might be a misspelling of, best first.
+	Suggestions []string
+}
+
+// A long option that's a prefix of several registered ones, when
+// abbreviations are enabled in the ParserConfig.
+type ErrorAmbiguousOption struct {
+	// The option as given, with its dashes.
+	A    string
+	Span *Span
+	// The options it's a prefix of, with their dashes, sorted.
+	Candidates []string
+}
+
+// A response file named with @path that couldn't be expanded, when
+// response files are enabled in the ParserConfig.
+type ErrorResponseFile struct {
+	// The response file with the problem.
+	Path string
+	// The line of Path the problem is on, counting from 1, or 0 if Path
+	// couldn't be read at all.
+	Line   int
+	Error2 error
+	// The span of the @path argument on the command line.
+	Span *Span
+}
+
 var _ Error = (*ErrorMissingValue)(nil)
 var _ Error = (*ErrorUnexpectedOption)(nil)
 var _ Error = (*ErrorUnexpectedArgument)(nil)
@@ -42,6 +97,9 @@ var _ Error = (*ErrorUnexpectedValue)(nil)
 var _ Error = (*ErrorParsingFailed)(nil)
 var _ Error = (*ErrorNonUnicodeValue)(nil)
 var _ Error = (*ErrorCustom)(nil)
+var _ Error = (*ErrorUnknownSubcommand)(nil)
+var _ Error = (*ErrorAmbiguousOption)(nil)
+var _ Error = (*ErrorResponseFile)(nil)
 
 func (ErrorMissingValue) isError()       {}
 func (ErrorUnexpectedOption) isError()   {}
@@ -50,6 +108,9 @@ func (ErrorUnexpectedValue) isError()    {}
 func (ErrorParsingFailed) isError()      {}
 func (ErrorNonUnicodeValue) isError()    {}
 func (ErrorCustom) isError()             {}
+func (ErrorUnknownSubcommand) isError()  {}
+func (ErrorAmbiguousOption) isError()    {}
+func (ErrorResponseFile) isError()       {}
 
 func (e *ErrorMissingValue) String() string {
 	if e.Option == nil {
@@ -59,6 +120,9 @@ func (e *ErrorMissingValue) String() string {
 	}
 }
 func (e *ErrorUnexpectedOption) String() string {
+	if hint := didYouMean(e.Suggestions); hint != "" {
+		return fmt.Sprintf("invalid option '%v'; %v", e.A, hint)
+	}
 	return fmt.Sprintf("invalid option '%v'", e.A)
 }
 func (e *ErrorUnexpectedArgument) String() string {
@@ -71,11 +135,31 @@ func (e *ErrorNonUnicodeValue) String() string {
 	return fmt.Sprintf("argument is invalid unicode: %#+v", e.A)
 }
 func (e *ErrorParsingFailed) String() string {
-	return fmt.Sprintf("cannot parse argument %#+v: %v", e.Value, e.Error2)
+	if e.Option == nil {
+		return fmt.Sprintf("cannot parse argument %#+v: %v", e.Value, e.Error2)
+	} else {
+		return fmt.Sprintf("invalid value '%v' for '%v': %v", e.Value, *e.Option, e.Error2)
+	}
 }
 func (e *ErrorCustom) String() string {
 	return fmt.Sprint(e.A)
 }
+func (e *ErrorUnknownSubcommand) String() string {
+	if hint := didYouMean(e.Suggestions); hint != "" {
+		return fmt.Sprintf("unknown subcommand '%v'; %v", e.A, hint)
+	}
+	return fmt.Sprintf("unknown subcommand '%v'", e.A)
+}
+func (e *ErrorAmbiguousOption) String() string {
+	return fmt.Sprintf("option '%v' is ambiguous; could be %v", e.A, quotedList(e.Candidates))
+}
+func (e *ErrorResponseFile) String() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("response file '%v': %v", e.Path, e.Error2)
+	} else {
+		return fmt.Sprintf("response file '%v', line %v: %v", e.Path, e.Line, e.Error2)
+	}
+}
 
 func (e *ErrorMissingValue) GoString() string {
 	return e.String()
@@ -98,6 +182,15 @@ func (e *ErrorParsingFailed) GoString() string {
 func (e *ErrorCustom) GoString() string {
 	return e.String()
 }
+func (e *ErrorUnknownSubcommand) GoString() string {
+	return e.String()
+}
+func (e *ErrorAmbiguousOption) GoString() string {
+	return e.String()
+}
+func (e *ErrorResponseFile) GoString() string {
+	return e.String()
+}
 
 func (e *ErrorMissingValue) Error() string {
 	return e.String()
@@ -120,6 +213,15 @@ func (e *ErrorParsingFailed) Error() string {
 func (e *ErrorCustom) Error() string {
 	return e.String()
 }
+func (e *ErrorUnknownSubcommand) Error() string {
+	return e.String()
+}
+func (e *ErrorAmbiguousOption) Error() string {
+	return e.String()
+}
+func (e *ErrorResponseFile) Error() string {
+	return e.String()
+}
 
 func (e *ErrorMissingValue) Unwrap() error {
 	return nil
@@ -141,4 +243,13 @@ func (e *ErrorParsingFailed) Unwrap() error {
 }
 func (e *ErrorCustom) Unwrap() error {
 	return e.A
-}
\ No newline at end of file
+}
+func (e *ErrorUnknownSubcommand) Unwrap() error {
+	return nil
+}
+func (e *ErrorAmbiguousOption) Unwrap() error {
+	return nil
+}
+func (e *ErrorResponseFile) Unwrap() error {
+	return e.Error2
+}
@@ -0,0 +1,46 @@
+/*
+The hello example again, but with lexopt.Bind() filling in a struct
+instead of a hand-written loop.
+*/
+package lexopt_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jcbhmr/go-lexopt"
+)
+
+type bindArgs struct {
+	Thing  string `lexopt:"positional,value=THING"`
+	Number uint32 `lexopt:"-n,--number,value=NUM"`
+	Shout  bool   `lexopt:"--shout"`
+}
+
+func Example_bind() {
+	os.Args = []string{"hello", "-n", "2", "--shout", "Grace Hopper"}
+
+	log.SetFlags(0)
+
+	args := bindArgs{Number: 1}
+	if err := lexopt.Bind(lexopt.ParserFromEnv(), &args); err != nil {
+		log.Fatal(err)
+	}
+	if args.Thing == "" {
+		log.Fatal("missing argument THING")
+	}
+
+	message := fmt.Sprintf("Hello %s!", args.Thing)
+	if args.Shout {
+		message = strings.ToUpper(message)
+	}
+	for i := uint32(0); i < args.Number; i++ {
+		fmt.Println(message)
+	}
+
+	// Output:
+	// HELLO GRACE HOPPER!
+	// HELLO GRACE HOPPER!
+}
@@ -10,7 +10,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/jcbhmr/go-lexopt"
@@ -32,10 +31,14 @@ func Example_cargo() {
 		verbose:   false,
 	}
 
+	var commands lexopt.Dispatcher
+	commands.Handle("install", func(parser *lexopt.Parser) lexopt.Error {
+		return install(settings, parser)
+	}, "i")
+
 	parser := lexopt.ParserFromEnv()
 	for {
 		arg, ok, err := parser.Next()
-		fmt.Printf("parser=%#+v, arg=%#+v, ok=%#+v, err=%#+v\n", parser, arg, ok, err)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -67,14 +70,12 @@ func Example_cargo() {
 		} else if value, ok := arg.(Value); ok {
 			if strings.HasPrefix(value.A, "+") {
 				settings.toolchain = value.A[1:]
-			} else if value.A == "install" {
-				err := install(settings, parser)
-				if err != nil {
+			} else {
+				// install() gets a parser named "cargo install".
+				if err := commands.Dispatch(parser, value.A); err != nil {
 					log.Fatal(err)
 				}
 				return
-			} else {
-				log.Fatalf("Unknown subcommand '%v'", value.A)
 			}
 		} else {
 			log.Fatal(arg.Unexpected())
@@ -84,7 +85,7 @@ func Example_cargo() {
 	fmt.Println(help)
 
 	// Output:
-	// Settings: lexopt_test.globalSettings{toolchain:"nightly", color:0, offline:false, quiet:false, verbose:true}
+	// Settings: lexopt_test.globalSettings{toolchain:"nightly", color:0x2, offline:false, quiet:false, verbose:true}
 	// Installing hello into /home/octocat/project1 with 8 jobs
 }
 
@@ -120,17 +121,13 @@ func install(settings globalSettings, parser *lexopt.Parser) lexopt.Error {
 			}
 			root = &rootText
 		} else if (argShortOk && argShort.A == 'j') || (argLongOk && argLong.A == "jobs") {
-			jobsText, err := parser.Value()
+			jobs, err = lexopt.ValueAs[uint16](parser)
 			if err != nil {
 				return err
 			}
-			jobs64, err2 := strconv.ParseUint(jobsText, 10, 16)
-			if err2 != nil {
-				return &lexopt.ErrorCustom{err2}
-			}
-			jobs = uint16(jobs64)
 		} else if argV, ok := arg.(Long); ok && argV.A == "help" {
-			fmt.Println("cargo install [OPTIONS] CRATE")
+			binName, _ := parser.BinName()
+			fmt.Printf("%v [OPTIONS] CRATE\n", binName)
 			os.Exit(0)
 		} else {
 			return arg.Unexpected()
@@ -141,7 +138,11 @@ func install(settings globalSettings, parser *lexopt.Parser) lexopt.Error {
 	if package_ == nil {
 		return &lexopt.ErrorCustom{errors.New("missing CRATE argument")}
 	}
-	fmt.Printf("Installing %v into %#v with %v jobs\n", *package_, root, jobs)
+	if root != nil {
+		fmt.Printf("Installing %v into %v with %v jobs\n", *package_, *root, jobs)
+	} else {
+		fmt.Printf("Installing %v with %v jobs\n", *package_, jobs)
+	}
 
 	return nil
 }
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/jcbhmr/go-lexopt"
@@ -32,15 +31,10 @@ func parseArgs() (args, error) {
 			break
 		}
 		if (arg == Short{'n'}) || (arg == Long{"number"}) {
-			numberText, err := parser.Value()
+			number, err = lexopt.ValueAs[uint32](parser)
 			if err != nil {
 				return args{}, err
 			}
-			number64, err2 := strconv.ParseUint(numberText, 10, 32)
-			if err2 != nil {
-				return args{}, err2
-			}
-			number = uint32(number64)
 		} else if (arg == Long{"shout"}) {
 			shout = true
 		} else if val, ok := arg.(Value); thing == nil && ok {
@@ -83,4 +77,6 @@ func Example_hello() {
 
 	// Output:
 	// HELLO ALAN TURING!
+	// HELLO ALAN TURING!
+	// HELLO ALAN TURING!
 }
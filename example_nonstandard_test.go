@@ -2,8 +2,8 @@
 Some programs accept options with an unusual syntax. For example, tail
 accepts -13 as an alias for -n 13.
 
-This program shows how to use parser.TryRawArgs() to handle them
-manually.
+This program shows how to use parser.TryRawArgs() and parser.Checkpoint()
+to handle them manually.
 
 (Note: actual tail implementations handle it slightly differently! This
 is just an example.)
@@ -25,15 +25,17 @@ func parseDashnum(parser *lexopt.Parser) (uint64, bool) {
 	if !ok {
 		return 0, false
 	}
-	arg, ok := raw.Peek()
+	// Take the argument, and put it back if it turns out not to be a number.
+	checkpoint := parser.Checkpoint()
+	arg, ok := raw.Next()
 	if !ok {
 		return 0, false
 	}
 	num, err := strconv.ParseUint(strings.TrimPrefix(arg, "-"), 10, 64)
 	if err != nil {
+		parser.Restore(checkpoint)
 		return 0, false
 	}
-	raw.Next() // Consume the argument we just parsed
 	return num, true
 }
 
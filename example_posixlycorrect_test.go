@@ -1 +1,88 @@
+/*
+A command that runs another command, like nice or env, where the options
+end at the first positional argument so that the command's own options are
+left alone.
+
+This is what POSIX getopt() always does, and what GNU getopt() does when
+POSIXLY_CORRECT is set. Without it, -l and --color below would be taken as
+options of nice.
+*/
 package lexopt_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jcbhmr/go-lexopt"
+	. "github.com/jcbhmr/go-lexopt/prelude"
+)
+
+type niceArgs struct {
+	adjustment int
+	verbose    bool
+	command    []string
+}
+
+func parseNiceArgs(posixlyCorrect bool) (niceArgs, error) {
+	args := niceArgs{adjustment: 10}
+	parser := lexopt.ParserFromEnv().Configure(lexopt.ParserConfig{
+		StopAtFirstPositional: posixlyCorrect,
+	})
+	for {
+		arg, ok, err := parser.Next()
+		if err != nil {
+			return niceArgs{}, err
+		}
+		if !ok {
+			break
+		}
+		if (arg == Short{'n'}) || (arg == Long{"adjustment"}) {
+			args.adjustment, err = lexopt.ValueAs[int](parser)
+			if err != nil {
+				return niceArgs{}, err
+			}
+		} else if (arg == Short{'v'}) || (arg == Long{"verbose"}) {
+			args.verbose = true
+		} else if val, ok := arg.(Value); ok {
+			// Once the command starts everything is a Value, even -l.
+			args.command = append(args.command, val.A)
+		} else if (arg == Long{"help"}) {
+			fmt.Println("Usage: nice [-n|--adjustment=N] [-v] COMMAND [ARG]...")
+			os.Exit(0)
+		} else {
+			return niceArgs{}, arg.Unexpected()
+		}
+	}
+	if len(args.command) == 0 {
+		return niceArgs{}, fmt.Errorf("missing argument COMMAND")
+	}
+	return args, nil
+}
+
+func Example_posixlyCorrect() {
+	os.Args = []string{"nice", "-n", "5", "-v", "ls", "-l", "--color=never", "src"}
+
+	log.SetFlags(0)
+
+	// Always stop at the first positional argument. To leave it to the
+	// user instead, as GNU tools do, pass lexopt.PosixlyCorrect().
+	args, err := parseNiceArgs(true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("adjustment: %v\n", args.adjustment)
+	fmt.Printf("verbose: %v\n", args.verbose)
+	fmt.Printf("command: %v\n", strings.Join(args.command, " "))
+
+	// Without it, ls's options are nice's, and it doesn't know them.
+	_, err = parseNiceArgs(false)
+	fmt.Printf("error: %v\n", err)
+
+	// Output:
+	// adjustment: 5
+	// verbose: true
+	// command: ls -l --color=never src
+	// error: invalid option '-l'
+}
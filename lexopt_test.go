@@ -3,10 +3,15 @@ package lexopt
 // TODO: Split these tests into more manageable chunks.
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -24,19 +29,11 @@ func TestBasic(t *testing.T) {
 	p := parse("-n 10 foo - -- baz -qux")
 	t.Logf("p=%#+v", p)
 
-	valuesIter, err := p.Values()
-	if err != nil {
-		t.Fatal(err)
-	}
-	values := slices.Collect(valuesIter.All)
-	t.Log(values)
-	return
-
 	next, ok, err := p.Next()
 	t.Logf("p=%#+v, next=%#+v, ok=%#+v, err=%#+v", p, next, ok, err)
 	require.Nil(t, err)
 	require.True(t, ok)
-	require.Equal(t, (Arg)(&Short{'n'}), next)
+	require.Equal(t, (Arg)(Short{'n'}), next)
 
 	value, err := p.Value()
 	t.Logf("p=%#+v, value=%#+v, err=%#+v", p, value, err)
@@ -50,25 +47,25 @@ func TestBasic(t *testing.T) {
 	t.Logf("p=%#+v, next=%#+v, ok=%#+v, err=%#+v", p, next, ok, err)
 	require.Nil(t, err)
 	require.True(t, ok)
-	require.Equal(t, (Arg)(&Value{"foo"}), next)
+	require.Equal(t, (Arg)(Value{"foo"}), next)
 
 	next, ok, err = p.Next()
 	t.Logf("p=%#+v, next=%#+v, ok=%#+v, err=%#+v", p, next, ok, err)
 	require.Nil(t, err)
 	require.True(t, ok)
-	require.Equal(t, (Arg)(&Value{"-"}), next)
+	require.Equal(t, (Arg)(Value{"-"}), next)
 
 	next, ok, err = p.Next()
 	t.Logf("p=%#+v, next=%#+v, ok=%#+v, err=%#+v", p, next, ok, err)
 	require.Nil(t, err)
 	require.True(t, ok)
-	require.Equal(t, (Arg)(&Value{"baz"}), next)
+	require.Equal(t, (Arg)(Value{"baz"}), next)
 
 	next, ok, err = p.Next()
 	t.Logf("p=%#+v, next=%#+v, ok=%#+v, err=%#+v", p, next, ok, err)
 	require.Nil(t, err)
 	require.True(t, ok)
-	require.Equal(t, (Arg)(&Value{"-qux"}), next)
+	require.Equal(t, (Arg)(Value{"-qux"}), next)
 
 	next, ok, err = p.Next()
 	t.Logf("p=%#+v, next=%#+v, ok=%#+v, err=%#+v", p, next, ok, err)
@@ -90,37 +87,29 @@ func TestCombined(t *testing.T) {
 	p := parse("-abc -fvalue -xfvalue")
 	t.Logf("p=%#+v", p)
 
-	valuesIter, err := p.Values()
-	if err != nil {
-		t.Fatal(err)
-	}
-	values := slices.Collect(valuesIter.All)
-	t.Log(values)
-	return
-
 	next, ok, err := p.Next()
 	t.Logf("p=%#+v, next=%#+v, ok=%#+v, err=%#+v", p, next, ok, err)
 	require.Nil(t, err)
 	require.True(t, ok)
-	require.Equal(t, (Arg)(&Short{'a'}), next)
+	require.Equal(t, (Arg)(Short{'a'}), next)
 
 	next, ok, err = p.Next()
 	t.Logf("p=%#+v, next=%#+v, ok=%#+v, err=%#+v", p, next, ok, err)
 	require.Nil(t, err)
 	require.True(t, ok)
-	require.Equal(t, (Arg)(&Short{'b'}), next)
+	require.Equal(t, (Arg)(Short{'b'}), next)
 
 	next, ok, err = p.Next()
 	t.Logf("p=%#+v, next=%#+v, ok=%#+v, err=%#+v", p, next, ok, err)
 	require.Nil(t, err)
 	require.True(t, ok)
-	require.Equal(t, (Arg)(&Short{'c'}), next)
+	require.Equal(t, (Arg)(Short{'c'}), next)
 
 	next, ok, err = p.Next()
 	t.Logf("p=%#+v, next=%#+v, ok=%#+v, err=%#+v", p, next, ok, err)
 	require.Nil(t, err)
 	require.True(t, ok)
-	require.Equal(t, (Arg)(&Short{'f'}), next)
+	require.Equal(t, (Arg)(Short{'f'}), next)
 
 	value, err := p.Value()
 	t.Logf("p=%#+v, value=%#+v, err=%#+v", p, value, err)
@@ -131,12 +120,12 @@ func TestCombined(t *testing.T) {
 	t.Logf("p=%#+v, next=%#+v, ok=%#+v, err=%#+v", p, next, ok, err)
 	require.Nil(t, err)
 	require.True(t, ok)
-	require.Equal(t, (Arg)(&Short{'x'}), next)
+	require.Equal(t, (Arg)(Short{'x'}), next)
 
 	next, ok, err = p.Next()
 	require.Nil(t, err)
 	require.True(t, ok)
-	require.Equal(t, (Arg)(&Short{'f'}), next)
+	require.Equal(t, (Arg)(Short{'f'}), next)
 
 	value, err = p.Value()
 	t.Logf("p=%#+v, value=%#+v, err=%#+v", p, value, err)
@@ -147,3 +136,846 @@ func TestCombined(t *testing.T) {
 	require.Nil(t, err)
 	require.False(t, ok)
 }
+
+func TestValueAs(t *testing.T) {
+	p := parse("-n 10 --ratio=0.5 -v=yes --wait 2s --name x -j abc")
+
+	next, _, _ := p.Next()
+	require.Equal(t, (Arg)(Short{'n'}), next)
+	n, err := ValueAs[uint16](p)
+	require.Nil(t, err)
+	require.Equal(t, uint16(10), n)
+
+	p.Next()
+	ratio, err := ValueAs[float64](p)
+	require.Nil(t, err)
+	require.Equal(t, 0.5, ratio)
+
+	p.Next()
+	_, err = ValueAs[bool](p)
+	require.IsType(t, &ErrorParsingFailed{}, err)
+	require.Equal(t, `invalid value 'yes' for '-v': invalid syntax`, err.Error())
+	require.ErrorIs(t, err, strconv.ErrSyntax)
+
+	p.Next()
+	wait, err := ValueAs[time.Duration](p)
+	require.Nil(t, err)
+	require.Equal(t, 2*time.Second, wait)
+
+	p.Next()
+	name, err := ValueAs[*string](p)
+	require.Nil(t, err)
+	require.Equal(t, "x", *name)
+
+	p.Next()
+	_, err = ParseWith(p, func(s string) (int, error) {
+		return 0, errors.New("not a number")
+	})
+	require.Equal(t, `invalid value 'abc' for '-j': not a number`, err.Error())
+	require.Equal(t, 8, err.(*ErrorParsingFailed).Index)
+
+	_, err = ValueAs[int](p)
+	require.IsType(t, &ErrorMissingValue{}, err)
+}
+
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
+func TestValueAsInterfaces(t *testing.T) {
+	l, err := ParseValue[level]("high")
+	require.Nil(t, err)
+	require.Equal(t, level(2), l)
+
+	s, err := ParseValue[stringsValue]("a,b")
+	require.Nil(t, err)
+	require.Equal(t, stringsValue{"a", "b"}, s)
+
+	_, err = ParseValue[uint8]("256")
+	require.ErrorIs(t, err, strconv.ErrRange)
+}
+
+type stringsValue []string
+
+func (s *stringsValue) String() string { return strings.Join(*s, ",") }
+func (s *stringsValue) Set(value string) error {
+	*s = append(*s, strings.Split(value, ",")...)
+	return nil
+}
+
+func TestParsingFailed(t *testing.T) {
+	p := parse("--jobs abc -xj8 --port=http")
+
+	p.Next()
+	value, _ := p.Value()
+	err := p.ParsingFailed(value, strconv.ErrSyntax)
+	require.Equal(t, &ErrorParsingFailed{
+		Option: ptr("--jobs"),
+		Index:  0,
+		Value:  "abc",
+		Error2: strconv.ErrSyntax,
+		Span:   &Span{Index: 1, Start: 0, End: 3},
+	}, err)
+	require.Equal(t, "invalid value 'abc' for '--jobs': invalid syntax", err.Error())
+
+	p.Next()
+	p.Next()
+	_, err = ValueAs[int](p)
+	require.Nil(t, err)
+
+	p.Next()
+	_, err = ValueAs[int](p)
+	require.Equal(t, "--port", *err.(*ErrorParsingFailed).Option)
+	require.Equal(t, 3, err.(*ErrorParsingFailed).Index)
+
+	err = (&ErrorParsingFailed{Value: "abc", Error2: strconv.ErrSyntax})
+	require.Equal(t, `cannot parse argument "abc": invalid syntax`, err.Error())
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestSpans(t *testing.T) {
+	p := parse("-ab --long=value -o=x -- -c")
+
+	type token struct {
+		arg  Arg
+		span Span
+	}
+	next := func() token {
+		arg, ok, err := p.Next()
+		require.Nil(t, err)
+		require.True(t, ok)
+		return token{arg, p.Span()}
+	}
+
+	require.Equal(t, token{Short{'a'}, Span{Index: 0, Start: 1, End: 2}}, next())
+	require.Equal(t, token{Short{'b'}, Span{Index: 0, Start: 2, End: 3}}, next())
+	require.Equal(t, token{Long{"long"}, Span{Index: 1, Start: 0, End: 6}}, next())
+	value, _ := p.Value()
+	require.Equal(t, "value", value)
+	require.Equal(t, Span{Index: 1, Start: 7, End: 12}, p.Span())
+	require.Equal(t, token{Short{'o'}, Span{Index: 2, Start: 1, End: 2}}, next())
+
+	_, _, err := p.Next()
+	require.Equal(t, &ErrorUnexpectedValue{
+		Option: "-o",
+		Value:  "x",
+		Span:   &Span{Index: 2, Start: 3, End: 4},
+	}, err)
+
+	require.Equal(t, token{Value{"-c"}, Span{Index: 4, Start: 0, End: 2}}, next())
+	require.Equal(t, &ErrorUnexpectedArgument{A: "-c", Span: &Span{Index: 4, Start: 0, End: 2}}, p.Unexpected(Value{"-c"}))
+
+	_, err = p.Value()
+	require.Equal(t, &ErrorMissingValue{Option: ptr("-o"), Span: &Span{Index: 2, Start: 1, End: 2}}, err)
+}
+
+func TestRenderError(t *testing.T) {
+	p := ParserFromIter(slices.Values([]string{"myapp", "-v", "--jobs=abc", "it's"}))
+	p.Next()
+	p.Next()
+	_, err := ValueAs[int](p)
+	require.Equal(t, ""+
+		"error: invalid value 'abc' for '--jobs': invalid syntax\n"+
+		"  myapp -v --jobs=abc 'it'\\''s'\n"+
+		"                  ^^^\n",
+		p.RenderError(err, false))
+
+	arg, _, _ := p.Next()
+	require.Equal(t, ""+
+		"error: unexpected argument \"it's\"\n"+
+		"  myapp -v --jobs=abc 'it'\\''s'\n"+
+		"                      ^^^^^^^^^\n",
+		p.RenderError(arg.Unexpected(), false))
+
+	_, err = p.Value()
+	require.Equal(t, ""+
+		"\x1b[1;31merror:\x1b[0m missing value for option '--jobs'\n"+
+		"  myapp -v --jobs=abc 'it'\\''s'\n"+
+		"           \x1b[1;31m^^^^^^\x1b[0m\n",
+		p.RenderError(err, true))
+
+	require.Equal(t, "error: oops\n", p.RenderError(errors.New("oops"), false))
+}
+
+func TestCheckpoint(t *testing.T) {
+	p := parse("-ab --long=value rest")
+	p.Next()
+	checkpoint := p.Checkpoint()
+
+	next, _, _ := p.Next()
+	require.Equal(t, (Arg)(Short{'b'}), next)
+	next, _, _ = p.Next()
+	require.Equal(t, (Arg)(Long{"long"}), next)
+
+	p.Restore(checkpoint)
+	next, _, _ = p.Next()
+	require.Equal(t, (Arg)(Short{'b'}), next)
+	require.Equal(t, Span{Index: 0, Start: 2, End: 3}, p.Span())
+	p.Next()
+
+	clone := p.Clone()
+	value, _ := clone.Value()
+	require.Equal(t, "value", value)
+	next, _, _ = clone.Next()
+	require.Equal(t, (Arg)(Value{"rest"}), next)
+
+	// The original still has the pending value.
+	_, _, err := p.Next()
+	require.IsType(t, &ErrorUnexpectedValue{}, err)
+
+	p.Restore(checkpoint)
+	value, _ = p.Value()
+	require.Equal(t, "b", value)
+}
+
+func TestPeek(t *testing.T) {
+	p := parse("-ab --opt=x -- -c")
+
+	peek := func() Arg {
+		arg, ok, err := p.Peek()
+		require.Nil(t, err)
+		require.True(t, ok)
+		return arg
+	}
+
+	require.Equal(t, (Arg)(Short{'a'}), peek())
+	require.Equal(t, (Arg)(Short{'a'}), peek())
+	p.Next()
+	require.Equal(t, (Arg)(Short{'b'}), peek())
+	p.Next()
+	require.Equal(t, (Arg)(Long{"opt"}), peek())
+	p.Next()
+
+	_, _, err := p.Peek()
+	require.IsType(t, &ErrorUnexpectedValue{}, err)
+	value, _ := p.Value()
+	require.Equal(t, "x", value)
+
+	require.Equal(t, (Arg)(Value{"-c"}), peek())
+	p.Next()
+	_, ok, err := p.Peek()
+	require.Nil(t, err)
+	require.False(t, ok)
+}
+
+func TestValues(t *testing.T) {
+	p := parse("--opt=a b --opt c d -x")
+
+	p.Next()
+	values, err := p.Values()
+	require.Nil(t, err)
+	require.Equal(t, []string{"a"}, slices.Collect(values.All))
+	// An attached value is the only one, however often Next() is called.
+	_, ok := values.Next()
+	require.False(t, ok)
+
+	next, _, _ := p.Next()
+	require.Equal(t, (Arg)(Value{"b"}), next)
+	p.Next()
+	values, err = p.Values()
+	require.Nil(t, err)
+	require.Equal(t, []string{"c", "d"}, slices.Collect(values.All))
+	_, ok = values.Next()
+	require.False(t, ok)
+
+	next, _, _ = p.Next()
+	require.Equal(t, (Arg)(Short{'x'}), next)
+	_, err = p.Values()
+	require.IsType(t, &ErrorMissingValue{}, err)
+}
+
+func TestRegistry(t *testing.T) {
+	config := ParserConfig{
+		Shorts: map[rune]OptionKind{'o': OptionValue, 'v': OptionFlag, 'O': OptionOptionalValue},
+		Longs:  map[string]OptionKind{"output": OptionValue, "verbose": OptionFlag},
+	}
+
+	p := parse("-vofile -vx --bogus=1 -v").Configure(config)
+	next, _, err := p.Next()
+	require.Nil(t, err)
+	require.Equal(t, (Arg)(Short{'v'}), next)
+	next, _, err = p.Next()
+	require.Nil(t, err)
+	require.Equal(t, (Arg)(Short{'o'}), next)
+	// Forgetting to call Value() doesn't turn "file" into -f -i -l -e.
+	_, _, err = p.Next()
+	require.Equal(t, &ErrorUnexpectedValue{Option: "-o", Value: "file", Span: &Span{Index: 0, Start: 3, End: 7}}, err)
+
+	p.Next()
+	_, _, err = p.Next()
+	require.Equal(t, &ErrorUnexpectedOption{A: "-x", Span: &Span{Index: 1, Start: 2, End: 3}}, err)
+	_, _, err = p.Next()
+	require.Equal(t, &ErrorUnexpectedOption{A: "--bogus", Span: &Span{Index: 2, Start: 0, End: 7}}, err)
+	next, _, err = p.Next()
+	require.Nil(t, err)
+	require.Equal(t, (Arg)(Short{'v'}), next)
+
+	p = parse("-O3 -O --output").Configure(config)
+	p.Next()
+	value, ok := p.OptionalValue()
+	require.True(t, ok)
+	require.Equal(t, "3", value)
+	p.Next()
+	_, ok = p.OptionalValue()
+	require.False(t, ok)
+	_, _, err = p.Next()
+	require.Equal(t, &ErrorMissingValue{Option: ptr("--output"), Span: &Span{Index: 2, Start: 0, End: 8}}, err)
+
+	// Without a registry nothing changes.
+	p = parse("-ofile")
+	p.Next()
+	next, _, _ = p.Next()
+	require.Equal(t, (Arg)(Short{'f'}), next)
+}
+
+type bindArgs struct {
+	Number  uint32       `lexopt:"-n,--number,value=NUM"`
+	Shout   bool         `lexopt:"--shout"`
+	Quiet   *bool        `lexopt:"-q"`
+	Include []string     `lexopt:"-I,--include,value=DIR"`
+	Output  *string      `lexopt:"-o,--output,value=FILE"`
+	Level   level        `lexopt:"--level"`
+	Tags    stringsValue `lexopt:"--tags"`
+	Thing   string       `lexopt:"positional,value=THING"`
+	Rest    []string     `lexopt:"positional,value=FILE"`
+	ignored int
+}
+
+func TestBind(t *testing.T) {
+	cfg := bindArgs{Number: 1}
+	err := Bind(parse("-n3 --shout -Ia --include b --level=high --tags x,y --tags z thing f1 f2"), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, bindArgs{
+		Number:  3,
+		Shout:   true,
+		Include: []string{"a", "b"},
+		Level:   2,
+		Tags:    stringsValue{"x", "y", "z"},
+		Thing:   "thing",
+		Rest:    []string{"f1", "f2"},
+	}, cfg)
+
+	cfg = bindArgs{}
+	err = Bind(parse("-qo out"), &cfg)
+	require.Nil(t, err)
+	require.True(t, *cfg.Quiet)
+	require.Equal(t, "out", *cfg.Output)
+
+	err = Bind(parse("--bogus"), &cfg)
+	require.IsType(t, &ErrorUnexpectedOption{}, err)
+	err = Bind(parse("--output"), &cfg)
+	require.IsType(t, &ErrorMissingValue{}, err)
+	err = Bind(parse("--number=-1"), &cfg)
+	require.Equal(t, "invalid value '-1' for '--number': invalid syntax", err.Error())
+
+	var onlyOptions struct {
+		Verbose bool `lexopt:"-v"`
+	}
+	err = Bind(parse("-v extra"), &onlyOptions)
+	require.IsType(t, &ErrorUnexpectedArgument{}, err)
+
+	require.Panics(t, func() { Bind(parse(""), cfg) })
+	require.Panics(t, func() {
+		var bad struct {
+			X int `lexopt:"--x,bogus"`
+		}
+		Bind(parse(""), &bad)
+	})
+}
+
+func TestBindPositionalError(t *testing.T) {
+	var cfg struct {
+		Verbose bool `lexopt:"-v"`
+		Count   int  `lexopt:"positional"`
+	}
+	err := Bind(parse("-v x"), &cfg)
+	require.Equal(t, &ErrorParsingFailed{Value: "x", Error2: strconv.ErrSyntax, Span: &Span{Index: 1, Start: 0, End: 1}}, err)
+}
+
+// Run a parsing loop over a partial command line, with the cursor in the
+// last word, and report what's being completed.
+func completion(words ...string) Completion {
+	p := ParserFromCompletion(words, len(words)-1)
+	for {
+		arg, ok, err := p.Next()
+		if err != nil || !ok {
+			break
+		}
+		if arg == (Short{'c'}) || arg == (Long{"color"}) {
+			if _, err := p.Value(); err != nil {
+				break
+			}
+		} else if arg == (Long{"files"}) {
+			values, err := p.Values()
+			if err != nil {
+				break
+			}
+			for range values.All {
+			}
+		}
+	}
+	c, _ := p.Completion()
+	return c
+}
+
+func TestCompletion(t *testing.T) {
+	require.Equal(t, Completion{Kind: CompleteArgument}, completion("app", ""))
+	require.Equal(t, Completion{Kind: CompleteArgument, Prefix: "fo"}, completion("app", "-v", "fo"))
+	require.Equal(t, Completion{Kind: CompleteOption, Prefix: "-"}, completion("app", "-"))
+	require.Equal(t, Completion{Kind: CompleteOption, Prefix: "--"}, completion("app", "--"))
+	require.Equal(t, Completion{Kind: CompleteOption, Prefix: "--col"}, completion("app", "foo", "--col"))
+
+	// Pending Value() slots.
+	require.Equal(t, Completion{Kind: CompleteValue, Option: "--color", Prefix: "ne"}, completion("app", "--color", "ne"))
+	require.Equal(t, Completion{Kind: CompleteValue, Option: "--color", Prefix: "-"}, completion("app", "--color", "-"))
+	require.Equal(t, Completion{Kind: CompleteValue, Option: "--color", Before: "--color=", Prefix: "ne"}, completion("app", "--color=ne"))
+	require.Equal(t, Completion{Kind: CompleteValue, Option: "-c", Before: "-vc", Prefix: "ne"}, completion("app", "-vcne"))
+	require.Equal(t, Completion{Kind: CompleteValue, Option: "-c", Before: "-c=", Prefix: ""}, completion("app", "-c="))
+	require.Equal(t, Completion{Kind: CompleteValue, Option: "-c", Before: "-c"}, completion("app", "-c"))
+	require.Equal(t, Completion{Kind: CompleteValue, Option: "-c", Prefix: "al"}, completion("app", "-vc", "al"))
+	require.Equal(t, Completion{Kind: CompleteValue, Option: "--files", Prefix: "c"}, completion("app", "--files", "a", "b", "c"))
+	require.Equal(t, Completion{Kind: CompleteOption, Prefix: "--f"}, completion("app", "--files", "a", "--f"))
+
+	// Inside a cluster.
+	require.Equal(t, Completion{Kind: CompleteShort, Before: "-vx"}, completion("app", "-vx"))
+
+	// After --.
+	require.Equal(t, Completion{Kind: CompleteArgument, Prefix: "--co", AfterDashes: true}, completion("app", "--", "--co"))
+	require.Equal(t, Completion{Kind: CompleteOption, Prefix: "--"}, completion("app", "--color", "x", "--"))
+
+	// Words after the cursor are ignored.
+	p := ParserFromCompletion([]string{"app", "-", "--color", "never"}, 1)
+	_, ok, err := p.Next()
+	require.False(t, ok)
+	require.Nil(t, err)
+	c, ok := p.Completion()
+	require.True(t, ok)
+	require.Equal(t, Completion{Kind: CompleteOption, Prefix: "-"}, c)
+
+	// Peeking doesn't count as reaching the cursor.
+	p = ParserFromCompletion([]string{"app", "fo"}, 1)
+	p.Peek()
+	_, ok = p.Completion()
+	require.False(t, ok)
+	_, ok = ParserFromArgs(slices.Values([]string{"a"})).Completion()
+	require.False(t, ok)
+
+	words, cword, ok := CompletionRequest([]string{"app", "__complete", "2", "app", "--color", "n"})
+	require.True(t, ok)
+	require.Equal(t, []string{"app", "--color", "n"}, words)
+	require.Equal(t, 2, cword)
+	_, _, ok = CompletionRequest([]string{"app", "__complete", "3", "app", "x"})
+	require.False(t, ok)
+	_, _, ok = CompletionRequest([]string{"app", "--color", "never"})
+	require.False(t, ok)
+
+	var b strings.Builder
+	require.Nil(t, WriteCandidates(&b, Completion{Kind: CompleteValue, Before: "--color=", Prefix: "a"}, []Candidate{
+		{"always", "Always use\tcolors"},
+		{"auto", ""},
+		{"never", "Never use colors"},
+		{"a\tb", ""},
+	}))
+	require.Equal(t, "--color=always\tAlways use colors\n--color=auto\n", b.String())
+}
+
+func TestDispatch(t *testing.T) {
+	var log []string
+	var remote Dispatcher
+	remote.Handle("add", func(p *Parser) Error {
+		binName, _ := p.BinName()
+		log = append(log, binName)
+		for {
+			arg, ok, err := p.Next()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+			if arg == (Short{'f'}) {
+				log = append(log, "fetch")
+			} else if value, ok := arg.(Value); ok {
+				log = append(log, value.A)
+			} else {
+				return p.Unexpected(arg)
+			}
+		}
+	})
+	var commands Dispatcher
+	commands.Handle("remote", func(p *Parser) Error {
+		arg, ok, err := p.Next()
+		if err != nil || !ok {
+			return err
+		}
+		if value, ok := arg.(Value); ok {
+			return remote.Dispatch(p, value.A)
+		}
+		return p.Unexpected(arg)
+	}, "r")
+	commands.Handle("status", func(p *Parser) Error { return nil })
+	require.Equal(t, []string{"remote", "status"}, commands.Names())
+	name, ok := commands.Lookup("r")
+	require.True(t, ok)
+	require.Equal(t, "remote", name)
+	require.Panics(t, func() { commands.Handle("status", nil) })
+
+	run := func(args ...string) (*Parser, Error) {
+		log = nil
+		p := ParserFromIter(slices.Values(args))
+		arg, _, _ := p.Next()
+		return p, commands.Dispatch(p, arg.(Value).A)
+	}
+
+	_, err := run("vcs", "r", "add", "-f", "origin", "url")
+	require.Nil(t, err)
+	require.Equal(t, []string{"vcs remote add", "fetch", "origin", "url"}, log)
+
+	p, err := run("vcs", "remote", "add", "-x")
+	require.Equal(t, &ErrorUnexpectedOption{A: "-x", Span: &Span{Index: 2, Start: 1, End: 2}}, err)
+	require.Equal(t, "error: invalid option '-x'\n  vcs remote add -x\n                  ^\n", p.RenderError(err, false))
+
+	p, err = run("vcs", "remote", "delete")
+	require.Equal(t, &ErrorUnknownSubcommand{A: "delete", Span: &Span{Index: 1, Start: 0, End: 6}}, err)
+	require.Equal(t, "error: unknown subcommand 'delete'\n  vcs remote delete\n             ^^^^^^\n", p.RenderError(err, false))
+
+	// The parent continues after what the child consumed.
+	p = ParserFromIter(slices.Values([]string{"vcs", "status", "--short"}))
+	p.Next()
+	require.Nil(t, commands.Dispatch(p, "status"))
+	arg, ok, err := p.Next()
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, (Arg)(Long{"short"}), arg)
+
+	// But its span is still that of the subcommand.
+	p = ParserFromIter(slices.Values([]string{"vcs", "remote", "add", "-f"}))
+	p.Next()
+	span := p.Span()
+	require.Nil(t, commands.Dispatch(p, "remote"))
+	require.Equal(t, span, p.Span())
+
+	// The child keeps the parent's settings.
+	p, err = run("vcs", "remote", "add", "origin", "-x")
+	require.Equal(t, &ErrorUnexpectedOption{A: "-x", Span: &Span{Index: 3, Start: 1, End: 2}}, err)
+	p = ParserFromIter(slices.Values([]string{"vcs", "remote", "add", "origin", "-x"})).Configure(ParserConfig{StopAtFirstPositional: true})
+	p.Next()
+	log = nil
+	require.Nil(t, commands.Dispatch(p, "remote"))
+	require.Equal(t, []string{"vcs remote add", "origin", "-x"}, log)
+
+	// A subparser only renders its own part of the command line.
+	p = ParserFromIter(slices.Values([]string{"vcs", "-v", "remote", "--bogus"}))
+	p.Next()
+	p.Next()
+	child := p.Subparser("remote")
+	arg, _, _ = child.Next()
+	err = child.Unexpected(arg)
+	require.Equal(t, "error: invalid option '--bogus'\n  vcs remote --bogus\n             ^^^^^^^\n", child.RenderError(err, false))
+	require.Equal(t, "error: invalid option '--bogus'\n  vcs -v remote --bogus\n                ^^^^^^^\n", p.RenderError(err, false))
+
+	// Completion goes through subcommands.
+	p = ParserFromCompletion([]string{"vcs", "remote", "add", "-"}, 3)
+	p.Next()
+	commands.Dispatch(p, "remote")
+	c, ok := p.Completion()
+	require.True(t, ok)
+	require.Equal(t, Completion{Kind: CompleteOption, Prefix: "-"}, c)
+}
+
+func TestSuggest(t *testing.T) {
+	longs := []string{"--verbose", "--version", "--quiet", "--color", "--colour", "--jobs"}
+	require.Equal(t, []string{"--verbose"}, Suggest("--verbsoe", longs))
+	require.Equal(t, []string{"--verbose", "--version"}, Suggest("--ver", longs))
+	require.Equal(t, []string{"--version"}, Suggest("--VERSOIN", []string{"--verbose", "--version"}))
+	require.Equal(t, []string{"--color"}, Suggest("--colr", longs))
+	require.Equal(t, []string{"--jobs"}, Suggest("--job", longs))
+	require.Equal(t, []string{"--quiet"}, Suggest("--quite", longs))
+	require.Nil(t, Suggest("--output", longs))
+	require.Nil(t, Suggest("--", longs))
+	require.Nil(t, Suggest("--jobs", longs))
+	require.Equal(t, []string{"install"}, Suggest("isntall", []string{"build", "install", "uninstall"}))
+
+	require.Equal(t, 0, editDistance([]rune("abc"), []rune("abc")))
+	require.Equal(t, 1, editDistance([]rune("abc"), []rune("acb")))
+	require.Equal(t, 1, editDistance([]rune("abc"), []rune("ab")))
+	require.Equal(t, 3, editDistance([]rune("ca"), []rune("abc")))
+	require.Equal(t, 3, editDistance([]rune(""), []rune("abc")))
+
+	p := ParserFromArgs(slices.Values([]string{"--verbsoe", "-x"})).Configure(ParserConfig{
+		Shorts: map[rune]OptionKind{'v': OptionFlag},
+		Longs:  map[string]OptionKind{"verbose": OptionFlag, "version": OptionFlag},
+	})
+	_, _, err := p.Next()
+	require.Equal(t, &ErrorUnexpectedOption{A: "--verbsoe", Span: &Span{Index: 0, Start: 0, End: 9}, Suggestions: []string{"--verbose"}}, err)
+	require.Equal(t, "invalid option '--verbsoe'; did you mean '--verbose'?", err.Error())
+	// Short options don't get suggestions.
+	_, _, err = p.Next()
+	require.Equal(t, &ErrorUnexpectedOption{A: "-x", Span: &Span{Index: 1, Start: 1, End: 2}}, err)
+
+	err = (&ErrorUnknownSubcommand{A: "x", Suggestions: []string{"a", "b", "c", "d"}})
+	require.Equal(t, "unknown subcommand 'x'; did you mean 'a', 'b' or 'c'?", err.Error())
+	err = (&ErrorUnexpectedOption{A: "--x", Suggestions: []string{"--a", "--b"}})
+	require.Equal(t, "invalid option '--x'; did you mean '--a' or '--b'?", err.Error())
+
+	var commands Dispatcher
+	commands.Handle("install", func(p *Parser) Error { return nil }, "add")
+	commands.Handle("uninstall", func(p *Parser) Error { return nil }, "remove")
+	p = ParserFromArgs(slices.Values([]string{"remvoe"}))
+	p.Next()
+	err = commands.Dispatch(p, "remvoe")
+	require.Equal(t, "unknown subcommand 'remvoe'; did you mean 'remove'?", err.Error())
+
+	var args bindArgs
+	err = Bind(ParserFromArgs(slices.Values([]string{"--shuot"})), &args)
+	require.Equal(t, "invalid option '--shuot'; did you mean '--shout'?", err.Error())
+}
+
+func TestAbbreviations(t *testing.T) {
+	config := ParserConfig{
+		Longs:         map[string]OptionKind{"verbose": OptionFlag, "version": OptionFlag, "color": OptionOptionalValue, "colors": OptionValue, "output": OptionValue},
+		Abbreviations: true,
+	}
+
+	p := parse("--verb --vers --color --out=file --colors=256 --ver=1 --outpt -v").Configure(config)
+	next, _, err := p.Next()
+	require.Nil(t, err)
+	require.Equal(t, (Arg)(Long{"verbose"}), next)
+	next, _, err = p.Next()
+	require.Nil(t, err)
+	require.Equal(t, (Arg)(Long{"version"}), next)
+	// An exact match isn't ambiguous.
+	next, _, err = p.Next()
+	require.Nil(t, err)
+	require.Equal(t, (Arg)(Long{"color"}), next)
+	next, _, err = p.Next()
+	require.Nil(t, err)
+	require.Equal(t, (Arg)(Long{"output"}), next)
+	value, err := p.Value()
+	require.Nil(t, err)
+	require.Equal(t, "file", value)
+	next, _, err = p.Next()
+	require.Nil(t, err)
+	require.Equal(t, (Arg)(Long{"colors"}), next)
+	p.Value()
+
+	_, _, err = p.Next()
+	require.Equal(t, &ErrorAmbiguousOption{A: "--ver", Candidates: []string{"--verbose", "--version"}, Span: &Span{Index: 5, Start: 0, End: 5}}, err)
+	require.Equal(t, "option '--ver' is ambiguous; could be '--verbose' or '--version'", err.Error())
+	// The value went with it.
+	_, _, err = p.Next()
+	require.Equal(t, &ErrorUnexpectedOption{A: "--outpt", Span: &Span{Index: 6, Start: 0, End: 7}, Suggestions: []string{"--output"}}, err)
+	// Short options are never abbreviated.
+	_, _, err = p.Next()
+	require.Equal(t, &ErrorUnexpectedOption{A: "-v", Span: &Span{Index: 7, Start: 1, End: 2}}, err)
+
+	// Errors name the full option, but point at what was typed.
+	p = parse("--out").Configure(config)
+	_, _, err = p.Next()
+	require.Equal(t, &ErrorMissingValue{Option: ptr("--output"), Span: &Span{Index: 0, Start: 0, End: 5}}, err)
+	p = parse("--verb=1").Configure(config)
+	p.Next()
+	_, _, err = p.Next()
+	require.Equal(t, &ErrorUnexpectedValue{Option: "--verbose", Value: "1", Span: &Span{Index: 0, Start: 7, End: 8}}, err)
+
+	p = parse("--c --col").Configure(config)
+	_, _, err = p.Next()
+	require.Equal(t, []string{"--color", "--colors"}, err.(*ErrorAmbiguousOption).Candidates)
+	require.Equal(t, "error: option '--c' is ambiguous; could be '--color' or '--colors'\n  --c --col\n  ^^^\n", p.RenderError(err, false))
+
+	// Off by default.
+	config.Abbreviations = false
+	p = parse("--verb").Configure(config)
+	_, _, err = p.Next()
+	require.IsType(t, &ErrorUnexpectedOption{}, err)
+}
+
+func TestResponseFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		require.Nil(t, os.WriteFile(path, []byte(content), 0o666))
+		return path
+	}
+	// Collect what Next() returns, as strings.
+	collect := func(p *Parser) ([]string, Error) {
+		var args []string
+		for {
+			arg, ok, err := p.Next()
+			if err != nil || !ok {
+				return args, err
+			}
+			if short, ok := arg.(Short); ok {
+				args = append(args, "-"+string(short.A))
+			} else if long, ok := arg.(Long); ok {
+				args = append(args, "--"+long.A)
+			} else if value, ok := arg.(Value); ok {
+				args = append(args, value.A)
+			}
+		}
+	}
+	config := ParserConfig{ResponseFiles: true}
+
+	inner := write("inner.txt", "-x 'in ner'\n")
+	outer := write("outer.txt", `# Flags for the build.
+-v   # verbose
+'single "quoted" \n' "double \"quoted\" \\ \n"
+back\ slash\
+ed
+""
+@`+inner+`
+--
+@`+inner+`
+`)
+	p := ParserFromIter(slices.Values([]string{"cc", "-a", "@" + outer, "b", "@" + inner, "--", "@" + inner})).Configure(config)
+	args, err := collect(p)
+	require.Nil(t, err)
+	require.Equal(t, []string{
+		"-a",
+		"-v", `single "quoted" \n`, `double "quoted" \ \n`, "back slashed", "", "-x", "in ner", "@" + inner,
+		"b", "@" + inner, "--", "@" + inner,
+	}, args)
+
+	// Values aren't expanded, and nothing is without ResponseFiles.
+	p = parse("-o @" + inner + " @" + inner).Configure(config)
+	p.Next()
+	value, _ := p.Value()
+	require.Equal(t, "@"+inner, value)
+	args, _ = collect(p)
+	require.Equal(t, []string{"-x", "in ner"}, args)
+	args, _ = collect(parse("@" + inner + " @"))
+	require.Equal(t, []string{"@" + inner, "@"}, args)
+
+	// Spans count the arguments of the command line. Arguments from a
+	// file point at the @path argument and name the file and line.
+	flags := write("flags.txt", "-a -b\n-c\n")
+	p = ParserFromIter(slices.Values([]string{"app", "@" + flags, "--bogus"})).Configure(ParserConfig{
+		ResponseFiles: true,
+		Shorts:        map[rune]OptionKind{'a': OptionFlag, 'b': OptionFlag},
+	})
+	p.Next()
+	p.Next()
+	_, _, err = p.Next()
+	require.Equal(t, &ErrorUnexpectedOption{A: "-c", Span: &Span{Index: 0, Start: 0, End: len(flags) + 1, File: flags, Line: 2}}, err)
+	require.Equal(t, "error: invalid option '-c'\n  app @"+flags+" --bogus\n      "+strings.Repeat("^", len(flags)+1)+"\n  in response file '"+flags+"', line 2\n", p.RenderError(err, false))
+	_, _, err = p.Next()
+	require.Equal(t, &ErrorUnexpectedOption{A: "--bogus", Span: &Span{Index: 1, Start: 0, End: 7}}, err)
+	require.Equal(t, "error: invalid option '--bogus'\n  app @"+flags+" --bogus\n      "+strings.Repeat(" ", len(flags)+2)+"^^^^^^^\n", p.RenderError(err, false))
+
+	// Nested files name the innermost file.
+	nested := write("nested.txt", "-v\n@"+inner+"\n")
+	p = parse("-v @" + nested).Configure(config)
+	p.Next()
+	require.Equal(t, Span{Index: 0, Start: 1, End: 2}, p.Span())
+	p.Next()
+	require.Equal(t, Span{Index: 1, Start: 0, End: len(nested) + 1, File: nested, Line: 1}, p.Span())
+	p.Next()
+	require.Equal(t, Span{Index: 1, Start: 0, End: len(nested) + 1, File: inner, Line: 1}, p.Span())
+
+	p = parse("a @" + filepath.Join(dir, "missing.txt")).Configure(config)
+	p.Next()
+	_, _, err = p.Next()
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.Equal(t, filepath.Join(dir, "missing.txt"), err.(*ErrorResponseFile).Path)
+	require.Equal(t, 0, err.(*ErrorResponseFile).Line)
+	require.Equal(t, &Span{Index: 1, Start: 0, End: len(filepath.Join(dir, "missing.txt")) + 1}, err.(*ErrorResponseFile).Span)
+
+	bad := write("bad.txt", "ok\n\n'not ok\n")
+	_, err = collect(parse("@" + bad).Configure(config))
+	require.Equal(t, "response file '"+bad+"', line 3: unterminated ' quote", err.Error())
+
+	broken := write("broken.txt", "-v\n@"+filepath.Join(dir, "missing.txt")+"\n")
+	_, err = collect(parse("@" + broken).Configure(config))
+	require.Equal(t, broken, err.(*ErrorResponseFile).Path)
+	require.Equal(t, 2, err.(*ErrorResponseFile).Line)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	a := filepath.Join(dir, "a.txt")
+	b := write("b.txt", "-b\n\n@"+a)
+	write("a.txt", "-a @"+b)
+	_, err = collect(parse("@" + a).Configure(config))
+	require.Equal(t, "response file '"+b+"', line 3: response file '"+a+"' includes itself", err.Error())
+
+	for i := range 20 {
+		write(fmt.Sprintf("%v.txt", i), fmt.Sprintf("@%v", filepath.Join(dir, fmt.Sprintf("%v.txt", i+1))))
+	}
+	_, err = collect(parse("@" + filepath.Join(dir, "0.txt")).Configure(config))
+	require.Equal(t, "response file '"+filepath.Join(dir, "15.txt")+"', line 1: response files nested more than 16 deep", err.Error())
+}
+
+func TestStopAtFirstPositional(t *testing.T) {
+	config := ParserConfig{StopAtFirstPositional: true}
+
+	p := parse("-v --color=never ls -l -- --all").Configure(config)
+	next, _, err := p.Next()
+	require.Nil(t, err)
+	require.Equal(t, (Arg)(Short{'v'}), next)
+	next, _, err = p.Next()
+	require.Nil(t, err)
+	require.Equal(t, (Arg)(Long{"color"}), next)
+	p.Value()
+	var rest []Arg
+	for {
+		next, ok, err := p.Next()
+		require.Nil(t, err)
+		if !ok {
+			break
+		}
+		rest = append(rest, next)
+	}
+	// Including the --, which only means something among options.
+	require.Equal(t, []Arg{Value{"ls"}, Value{"-l"}, Value{"--"}, Value{"--all"}}, rest)
+
+	// - is a positional argument too, and -- still ends options.
+	p = parse("- -x").Configure(config)
+	next, _, _ = p.Next()
+	require.Equal(t, (Arg)(Value{"-"}), next)
+	next, _, _ = p.Next()
+	require.Equal(t, (Arg)(Value{"-x"}), next)
+	p = parse("-- -x").Configure(config)
+	next, _, _ = p.Next()
+	require.Equal(t, (Arg)(Value{"-x"}), next)
+
+	// A subcommand gets options of its own, unless they came after --.
+	p = parse("-v commit -m msg").Configure(config)
+	p.Next()
+	p.Next()
+	sub := p.Subparser("commit")
+	next, _, _ = sub.Next()
+	require.Equal(t, (Arg)(Short{'m'}), next)
+	next, _, _ = p.Next()
+	require.Equal(t, (Arg)(Value{"-m"}), next)
+	p = parse("-- commit -m msg").Configure(config)
+	p.Next()
+	next, _, _ = p.Subparser("commit").Next()
+	require.Equal(t, (Arg)(Value{"-m"}), next)
+
+	// The state survives a checkpoint.
+	p = parse("ls -l").Configure(config)
+	checkpoint := p.Checkpoint()
+	p.Next()
+	after := p.Checkpoint()
+	p.Restore(checkpoint)
+	p.Restore(after)
+	next, _, _ = p.Next()
+	require.Equal(t, (Arg)(Value{"-l"}), next)
+
+	t.Setenv("POSIXLY_CORRECT", "")
+	require.True(t, PosixlyCorrect())
+	os.Unsetenv("POSIXLY_CORRECT")
+	require.False(t, PosixlyCorrect())
+}
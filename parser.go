@@ -15,12 +15,34 @@ type Parser struct {
 	source struct {
 		slice []string
 		index int
+		// Once a response file has been expanded into slice, the command
+		// line as it was given, and where each argument in slice came from.
+		args    []string
+		origins []argOrigin
 	}
 	state state
 	// The last option we emitted.
 	lastOption lastOption
 	// The name of the command (argv[0]).
 	binName *string
+	// For a subcommand's parser, the words that make up binName, like
+	// "cargo" and "install".
+	binNameParts []string
+	// The span of the last thing we consumed.
+	span Span
+	// The index of the first argument that belongs to this parser. It's
+	// more than 0 for a subcommand's parser from Subparser(), whose spans
+	// still count from the start of the whole command line.
+	base int
+	// Options the caller registered, if any.
+	config ParserConfig
+	// Whether the parser is for a partial command line, from
+	// ParserFromCompletion().
+	completing bool
+	// The index of the word under the cursor, if completing.
+	cursor int
+	// What's being completed, once the cursor is reached.
+	completion *Completion
 }
 
 type state interface {
@@ -42,7 +64,11 @@ type stateShorts struct {
 }
 
 // We saw -- and know no more options are coming.
-type stateFinishedOpts struct{}
+type stateFinishedOpts struct {
+	// Whether it was the first positional argument that ended the options,
+	// with ParserConfig.StopAtFirstPositional, rather than --.
+	atPositional bool
+}
 
 var _ state = (*stateNone)(nil)
 var _ state = (*statePendingValue)(nil)
@@ -61,10 +87,12 @@ type lastOption interface {
 }
 type lastOptionNone struct{}
 type lastOptionShort struct {
-	A rune
+	A    rune
+	span Span
 }
 type lastOptionLong struct {
-	A string
+	A    string
+	span Span
 }
 
 var _ lastOption = (*lastOptionNone)(nil)
@@ -84,19 +112,30 @@ func (lastOptionLong) isLastOption()  {}
 // ErrorUnexpectedValue is returned if the last option had a
 // value that hasn't been consumed, as in --option=value or -o=value.
 //
+// If the parser was configured with a ParserConfig that registers options,
+// ErrorUnexpectedOption is returned for unknown options and
+// ErrorMissingValue for options that need a value when none is left. With
+// abbreviations enabled, ErrorAmbiguousOption is returned for a prefix of
+// several long options, and with response files enabled,
+// ErrorResponseFile for an @path that can't be expanded.
+//
 // It's possible to continue parsing after this error (but this is rarely useful).
 func (p *Parser) Next() (Arg, bool, Error) {
 	if v1, ok := p.state.(statePendingValue); ok {
 		value := v1.a
 		// Last time we got --long=value, and value hasn't been used.
 		p.state = stateNone{}
+		index := p.source.index - 1
+		p.span = p.spanFrom(index, len(p.source.slice[index])-len(value))
 		option, ok := p.formatLastOption()
 		if !ok {
 			panic("Should only have pending value after long option")
 		}
+		span := p.span
 		return nil, false, &ErrorUnexpectedValue{
 			Option: option,
 			Value:  value,
+			Span:   &span,
 		}
 	} else if v2, ok := p.state.(stateShorts); ok {
 		arg := v2.a
@@ -105,13 +144,17 @@ func (p *Parser) Next() (Arg, bool, Error) {
 		// not .value(), we can assume that the next character is another option.
 		fcValue, fcOk, fcErr := firstCodepoint(arg[pos:])
 		if fcErr == nil && !fcOk {
+			if p.completeShorts(arg) {
+				return nil, false, nil
+			}
 			p.state = stateNone{}
-		} else if pos > 1 && fcErr == nil && fcOk && fcValue == '=' {
+		} else if pos > 1 && ((fcErr == nil && fcOk && fcValue == '=') || p.lastOptionTakesValue()) {
 			// If we find "-=[...]" we interpret is as an option.
 			// If we find "-o=..." then there's an unexpected value.
 			// ('-=' as an option exists, see https://linux.die.net/man/1/a2ps.)
 			// clap always interprets it as a short flag in this case, but
 			// that feels sloppy.
+			// If o is registered as taking a value then -ofile is the same.
 			option, ok := p.formatLastOption()
 			if !ok {
 				panic("unreachable")
@@ -120,30 +163,39 @@ func (p *Parser) Next() (Arg, bool, Error) {
 			if !ok {
 				panic("unreachable")
 			}
+			span := p.span
 			return nil, false, &ErrorUnexpectedValue{
 				Option: option,
 				Value:  value,
+				Span:   &span,
 			}
 		} else if fcErr == nil && fcOk {
-			pos += uint(utf8.RuneLen(fcValue))
+			end := pos + uint(utf8.RuneLen(fcValue))
+			p.span = p.spanAt(p.source.index-1, int(pos), int(end))
+			pos = end
 			p.state = stateShorts{arg, pos}
-			p.lastOption = lastOptionShort{fcValue}
-			return &ArgShort{fcValue}, true, nil
+			p.lastOption = lastOptionShort{fcValue, p.span}
+			return p.checkOption(ArgShort{fcValue})
 		} else if fcErr != nil {
 			// Advancing may allow recovery.
 			// This is a little iffy, there might be more bad unicode next.
+			p.span = p.spanFrom(p.source.index-1, int(pos))
 			pos = uint(len(arg))
 			p.state = stateShorts{arg, pos}
-			p.lastOption = lastOptionShort{'\uFFFD'}
-			return &ArgShort{'\uFFFD'}, true, nil
+			p.lastOption = lastOptionShort{'\uFFFD', p.span}
+			return p.checkOption(ArgShort{'\uFFFD'})
 		} else {
 			panic("unreachable")
 		}
 	} else if _, ok := p.state.(stateFinishedOpts); ok {
+		if p.completeWord() {
+			return nil, false, nil
+		}
 		if p.source.index < len(p.source.slice) {
 			v := p.source.slice[p.source.index]
+			p.span = p.spanFrom(p.source.index, 0)
 			p.source.index++
-			return &ArgValue{v}, true, nil
+			return ArgValue{v}, true, nil
 		} else {
 			return nil, false, nil
 		}
@@ -157,6 +209,10 @@ func (p *Parser) Next() (Arg, bool, Error) {
 		panic(fmt.Errorf("unepxected state %#+v", p.state))
 	}
 
+	if p.completeWord() {
+		return nil, false, nil
+	}
+
 	var arg2 string
 	{
 		if p.source.index < len(p.source.slice) {
@@ -173,6 +229,13 @@ func (p *Parser) Next() (Arg, bool, Error) {
 		return p.Next()
 	}
 
+	if path, ok := strings.CutPrefix(arg2, "@"); ok && path != "" && p.config.ResponseFiles {
+		if err := p.expandResponseFile(path); err != nil {
+			return nil, false, err
+		}
+		return p.Next()
+	}
+
 	// Fast solution for platforms where strings are just UTF-8-ish bytes.
 	arg3 := []byte(arg2)
 	if bytes.HasPrefix(arg3, []byte("--")) {
@@ -182,17 +245,38 @@ func (p *Parser) Next() (Arg, bool, Error) {
 			p.state = statePendingValue{string(arg3[ind+1:])}
 			arg3 = arg3[:ind]
 		}
+		p.span = p.spanAt(p.source.index-1, 0, len(arg3))
 		// ...but the options has to be a string.
 		option := strings.ToValidUTF8(string(arg3), "\uFFFD")
-		return p.setLong(option), true, nil
+		return p.checkOption(p.setLong(option))
 	} else if len(arg3) > 1 && arg3[0] == '-' {
 		p.state = stateShorts{arg3, 1}
 		return p.Next()
 	} else {
-		return &ArgValue{string(arg3)}, true, nil
+		p.span = p.spanFrom(p.source.index-1, 0)
+		if p.config.StopAtFirstPositional {
+			p.state = stateFinishedOpts{atPositional: true}
+		}
+		return ArgValue{string(arg3)}, true, nil
 	}
 }
 
+// Get what the next call to parser.Next() would return, without consuming it.
+//
+// This takes the current state into account: in the middle of -abc it
+// returns the next short option, after --option=value it returns the
+// ErrorUnexpectedValue that Next() would, and after -- everything is an
+// ArgValue. Unlike rawArgs.Peek() it can be called at any time.
+//
+// # Example
+//
+//	if arg, ok, _ := parser.Peek(); ok && arg == (Value{"help"}) {
+//	    // Route to the help subcommand before parsing anything else.
+//	}
+func (p *Parser) Peek() (Arg, bool, Error) {
+	return p.Clone().Next()
+}
+
 // Get a value for an option.
 //
 // This function should normally be called right after seeing an option
@@ -207,24 +291,22 @@ func (p *Parser) Next() (Arg, bool, Error) {
 // An ErrorMissingValue is returned if the end of the command
 // line is reached.
 func (p *Parser) Value() (string, Error) {
+	if p.completeValue(true) {
+		return "", p.missingValue()
+	}
+
 	if value, ok := p.OptionalValue(); ok {
 		return value, nil
 	}
 
 	if p.source.index < len(p.source.slice) {
 		value := p.source.slice[p.source.index]
+		p.span = p.spanFrom(p.source.index, 0)
 		p.source.index++
 		return value, nil
 	}
 
-	option, ok := p.formatLastOption()
-	var optionPtr *string
-	if ok {
-		optionPtr = &option
-	}
-	return "", &ErrorMissingValue{
-		Option: optionPtr,
-	}
+	return "", p.missingValue()
 }
 
 // Gather multiple values for an option.
@@ -285,20 +367,16 @@ func (p *Parser) Values() (*ValuesIter, Error) {
 	// differently.
 	// "--" is treated like an option and not consumed. This seems to me the
 	// least unreasonable behavior, and it's the easiest to implement.
+	if p.completeValue(true) {
+		return nil, p.missingValue()
+	}
 	if p.hasPending() || p.nextIsNormal() {
 		return &ValuesIter{
 			tookFirst: false,
 			parser:    p,
 		}, nil
 	} else {
-		option, ok := p.formatLastOption()
-		var optionPtr *string
-		if ok {
-			optionPtr = &option
-		}
-		return nil, &ErrorMissingValue{
-			Option: optionPtr,
-		}
+		return nil, p.missingValue()
 	}
 }
 
@@ -310,8 +388,12 @@ func (p *Parser) Values() (*ValuesIter, Error) {
 // argument.
 func (p *Parser) nextIfNormal() (string, bool) {
 	if p.nextIsNormal() {
+		if p.completeValue(true) {
+			return "", false
+		}
 		if p.source.index < len(p.source.slice) {
 			value := p.source.slice[p.source.index]
+			p.span = p.spanFrom(p.source.index, 0)
 			p.source.index++
 			return value, true
 		} else {
@@ -396,9 +478,11 @@ func (p *Parser) RawArgs() (*RawArgs, Error) {
 		if !ok {
 			panic("unreachable")
 		}
+		span := p.span
 		return nil, &ErrorUnexpectedValue{
 			Option: option,
 			Value:  value,
+			Span:   &span,
 		}
 	}
 
@@ -489,6 +573,61 @@ func (p *Parser) formatLastOption() (string, bool) {
 	}
 }
 
+// The span of the last option.
+func (p *Parser) lastOptionSpan() (Span, bool) {
+	if short, ok := p.lastOption.(lastOptionShort); ok {
+		return short.span, true
+	} else if long, ok := p.lastOption.(lastOptionLong); ok {
+		return long.span, true
+	} else {
+		return Span{}, false
+	}
+}
+
+func (p *Parser) missingValue() Error {
+	err := &ErrorMissingValue{}
+	if option, ok := p.formatLastOption(); ok {
+		err.Option = &option
+	}
+	if span, ok := p.lastOptionSpan(); ok {
+		err.Span = &span
+	}
+	return err
+}
+
+// Create an ErrorParsingFailed for a value that belongs to the last option.
+//
+// This is what ValueAs() and ParseWith() use. Call it yourself if you take
+// a value with parser.Value() and convert it some other way. It should be
+// called right after taking the value, since it records parser.Span().
+//
+// # Example
+//
+//	value, err := parser.Value()
+//	if err != nil {
+//	    return err
+//	}
+//	jobs, err2 := strconv.ParseUint(value, 10, 16)
+//	if err2 != nil {
+//	    return parser.ParsingFailed(value, err2)
+//	}
+func (p *Parser) ParsingFailed(value string, err error) Error {
+	option, ok := p.formatLastOption()
+	var optionPtr *string
+	if ok {
+		optionPtr = &option
+	}
+	optionSpan, _ := p.lastOptionSpan()
+	span := p.span
+	return &ErrorParsingFailed{
+		Option: optionPtr,
+		Index:  optionSpan.Index,
+		Value:  value,
+		Error2: err,
+		Span:   &span,
+	}
+}
+
 // The name of the command, as in the zeroth argument of the process.
 //
 // This is intended for use in messages. If the name is not valid unicode
@@ -514,6 +653,9 @@ func (p *Parser) BinName() (string, bool) {
 // Get a value only if it's concatenated to an option, as in -ovalue or
 // --option=value or -o=value, but not -o value or --option value.
 func (p *Parser) OptionalValue() (string, bool) {
+	if p.completeValue(false) {
+		return "", false
+	}
 	raw, _, ok := p.rawOptionalValue()
 	if !ok {
 		return "", false
@@ -523,10 +665,12 @@ func (p *Parser) OptionalValue() (string, bool) {
 
 // parser.OptionalValue(), but indicate whether the value was joined
 // with an = sign. This matters for parser.Values().
-func (p Parser) rawOptionalValue() (arg string, hadEqSign bool, ok bool) {
+func (p *Parser) rawOptionalValue() (arg string, hadEqSign bool, ok bool) {
 	prevState := p.state
 	p.state = stateNone{}
 	if pendingValue, ok := prevState.(statePendingValue); ok {
+		index := p.source.index - 1
+		p.span = p.spanFrom(index, len(p.source.slice[index])-len(pendingValue.a))
 		return pendingValue.a, true, true
 	} else if shorts, ok := prevState.(stateShorts); ok {
 		arg := shorts.a
@@ -542,11 +686,12 @@ func (p Parser) rawOptionalValue() (arg string, hadEqSign bool, ok bool) {
 			pos += 1
 			hadEqSign = true
 		}
+		p.span = p.spanFrom(p.source.index-1, int(pos))
 		arg = arg[pos:] // Reuse allocation
 		return string(arg), hadEqSign, true
 	} else if _, ok := prevState.(stateFinishedOpts); ok {
 		// Not really supposed to be here, but it's benign and not our fault
-		p.state = stateFinishedOpts{}
+		p.state = prevState
 		return "", false, false
 	} else if _, ok := prevState.(stateNone); ok {
 		return "", false, false
@@ -556,8 +701,10 @@ func (p Parser) rawOptionalValue() (arg string, hadEqSign bool, ok bool) {
 }
 
 func newParser(binName *string, source struct {
-	slice []string
-	index int
+	slice   []string
+	index   int
+	args    []string
+	origins []argOrigin
 }) *Parser {
 	var binName2 *string
 	if binName != nil {
@@ -585,9 +732,11 @@ func ParserFromEnv() *Parser {
 		source = os.Args
 	}
 	return newParser(binName, struct {
-		slice []string
-		index int
-	}{source, 0})
+		slice   []string
+		index   int
+		args    []string
+		origins []argOrigin
+	}{slice: source})
 }
 
 // Create a parser from an iterator. This is useful for testing among other things.
@@ -611,9 +760,11 @@ func ParserFromIter(args iter.Seq[string]) *Parser {
 		source = argsSlice
 	}
 	return newParser(binName, struct {
-		slice []string
-		index int
-	}{source, 0})
+		slice   []string
+		index   int
+		args    []string
+		origins []argOrigin
+	}{slice: source})
 }
 
 // Create a parser from an iterator that does **not** include a binary name.
@@ -625,16 +776,18 @@ func ParserFromIter(args iter.Seq[string]) *Parser {
 func ParserFromArgs(args iter.Seq[string]) *Parser {
 	argsSlice := slices.Collect(args)
 	return newParser(nil, struct {
-		slice []string
-		index int
-	}{argsSlice, 0})
+		slice   []string
+		index   int
+		args    []string
+		origins []argOrigin
+	}{slice: argsSlice})
 }
 
 // Store a long option so the caller can get it.
 func (p *Parser) setLong(option string) Arg {
-	p.lastOption = lastOptionLong{option}
+	p.lastOption = lastOptionLong{option, p.span}
 	if lastOption, ok := p.lastOption.(lastOptionLong); ok {
-		return &ArgLong{lastOption.A[2:]}
+		return ArgLong{lastOption.A[2:]}
 	} else {
 		panic("unreachable")
 	}
@@ -3,7 +3,7 @@ package lexopt
 import "iter"
 
 type RawArgs struct {
-	a *struct {slice []string; index int}
+	a *struct {slice []string; index int; args []string; origins []argOrigin}
 }
 
 var _ iter.Seq[string] = (*RawArgs)(nil).All
@@ -0,0 +1,192 @@
+package lexopt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// How deeply response files can include other response files.
+const maxResponseFileDepth = 16
+
+// One argument from a response file, with the file and the line it starts
+// on.
+type responseWord struct {
+	text string
+	path string
+	line int
+}
+
+// Replace the @path argument that was just read with the arguments in the
+// file, and move back so they're read next.
+//
+// The arguments remember where they came from, so spans still count the
+// arguments of the command line.
+func (p *Parser) expandResponseFile(path string) Error {
+	index := p.source.index - 1
+	p.span = p.spanFrom(index, 0)
+	r := responseFiles{}
+	words, err := r.expand(path, 0)
+	if err != nil {
+		span := p.span
+		err.Span = &span
+		return err
+	}
+	if p.source.origins == nil {
+		p.source.args = p.source.slice
+		p.source.origins = make([]argOrigin, len(p.source.slice))
+		for i := range p.source.origins {
+			p.source.origins[i] = argOrigin{index: i}
+		}
+	}
+	argIndex := p.source.origins[index].index
+	args := make([]string, len(words))
+	origins := make([]argOrigin, len(words))
+	for i, word := range words {
+		args[i] = word.text
+		origins[i] = argOrigin{argIndex, word.path, word.line}
+	}
+	p.source.slice = slices.Concat(p.source.slice[:index], args, p.source.slice[index+1:])
+	p.source.origins = slices.Concat(p.source.origins[:index], origins, p.source.origins[index+1:])
+	p.source.index = index
+	if p.completing && p.cursor > index {
+		p.cursor += len(args) - 1
+	}
+	return nil
+}
+
+// The state of expanding one response file from the command line.
+type responseFiles struct {
+	// The absolute paths of the files being read, outermost first.
+	stack []string
+	// Whether a -- was found, after which nothing is expanded.
+	finishedOpts bool
+}
+
+func (r *responseFiles) expand(path string, depth int) ([]responseWord, *ErrorResponseFile) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &ErrorResponseFile{Path: path, Error2: err}
+	}
+	words, err := splitResponseFile(string(content))
+	if err != nil {
+		return nil, &ErrorResponseFile{Path: path, Line: err.(*responseFileSyntaxError).line, Error2: err}
+	}
+	abs, _ := filepath.Abs(path)
+	r.stack = append(r.stack, abs)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	var args []responseWord
+	for _, word := range words {
+		nested, ok := strings.CutPrefix(word.text, "@")
+		if r.finishedOpts || !ok || nested == "" {
+			r.finishedOpts = r.finishedOpts || word.text == "--"
+			word.path = path
+			args = append(args, word)
+			continue
+		}
+		if nestedAbs, _ := filepath.Abs(nested); slices.Contains(r.stack, nestedAbs) {
+			return nil, &ErrorResponseFile{Path: path, Line: word.line, Error2: fmt.Errorf("response file '%v' includes itself", nested)}
+		}
+		if depth+1 >= maxResponseFileDepth {
+			return nil, &ErrorResponseFile{Path: path, Line: word.line, Error2: fmt.Errorf("response files nested more than %v deep", maxResponseFileDepth)}
+		}
+		more, err := r.expand(nested, depth+1)
+		if err != nil && err.Line == 0 {
+			// Blame the line that names the file that can't be read.
+			err = &ErrorResponseFile{Path: path, Line: word.line, Error2: err.Error2}
+		}
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, more...)
+	}
+	return args, nil
+}
+
+// A problem with the contents of a response file.
+type responseFileSyntaxError struct {
+	line    int
+	message string
+}
+
+func (e *responseFileSyntaxError) Error() string {
+	return e.message
+}
+
+// Split the contents of a response file into arguments.
+//
+// Arguments are separated by whitespace, including newlines. Single
+// quotes keep everything up to the next single quote as it is. Double
+// quotes do too, except that \" and \\ stand for " and \. Outside quotes
+// a backslash makes the next character literal, and a backslash at the
+// end of a line joins it to the next. A # at the start of an argument
+// starts a comment that runs to the end of the line.
+func splitResponseFile(content string) ([]responseWord, error) {
+	var words []responseWord
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	line, wordLine, quoteLine := 1, 1, 1
+	runes := []rune(content)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else if c == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+				i++
+				word.WriteRune(runes[i])
+			} else {
+				word.WriteRune(c)
+			}
+		case unicode.IsSpace(c):
+			if inWord {
+				words = append(words, responseWord{text: word.String(), line: wordLine})
+				word.Reset()
+				inWord = false
+			}
+		case c == '#' && !inWord:
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		default:
+			if !inWord {
+				inWord = true
+				wordLine = line
+			}
+			if c == '\'' || c == '"' {
+				quote = c
+				quoteLine = line
+			} else if c == '\\' && i+1 < len(runes) {
+				i++
+				if runes[i] != '\n' {
+					word.WriteRune(runes[i])
+				}
+			} else {
+				word.WriteRune(c)
+			}
+		}
+		// Whatever consumed it, a newline starts the next line.
+		if runes[i] == '\n' {
+			line++
+		}
+	}
+	if quote != 0 {
+		return nil, &responseFileSyntaxError{quoteLine, fmt.Sprintf("unterminated %c quote", quote)}
+	}
+	if inWord {
+		words = append(words, responseWord{text: word.String(), line: wordLine})
+	}
+	return words, nil
+}
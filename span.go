@@ -0,0 +1,125 @@
+package lexopt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A location on the command line.
+//
+// Index counts arguments from the first one after the binary name, so it's
+// an index into the iterator given to ParserFromArgs(), or into os.Args[1:]
+// for ParserFromEnv(). Start and End are byte offsets into that argument,
+// as a half-open range. For -abc the span of b is {Index, 2, 3}.
+//
+// Arguments from response files (see ParserConfig.ResponseFiles) aren't on
+// the command line, so their span is all of the @path argument they came
+// from, and File and Line say where in the file they are.
+type Span struct {
+	Index int
+	Start int
+	End   int
+	// The response file the argument came from, or "" if it was on the
+	// command line.
+	File string
+	// The line of File the argument starts on, counting from 1.
+	Line int
+}
+
+func (s Span) String() string {
+	if s.File != "" {
+		return fmt.Sprintf("%v:%v-%v (%v:%v)", s.Index, s.Start, s.End, s.File, s.Line)
+	}
+	return fmt.Sprintf("%v:%v-%v", s.Index, s.Start, s.End)
+}
+
+// The span of the argument at index, from start to its end.
+func (p *Parser) spanFrom(index int, start int) Span {
+	return p.spanAt(index, start, len(p.source.slice[index]))
+}
+
+// The span of bytes start to end of the argument at index. index counts
+// the arguments after response files were expanded, and the span counts
+// the arguments of the command line.
+func (p *Parser) spanAt(index int, start int, end int) Span {
+	if p.source.origins == nil {
+		return Span{Index: index, Start: start, End: end}
+	}
+	origin := p.source.origins[index]
+	if origin.file == "" {
+		return Span{Index: origin.index, Start: start, End: end}
+	}
+	return Span{Index: origin.index, Start: 0, End: len(p.source.args[origin.index]), File: origin.file, Line: origin.line}
+}
+
+// Where an argument came from: the argument of the command line, and the
+// response file and line if it was read from one.
+type argOrigin struct {
+	index int
+	file  string
+	line  int
+}
+
+// The index on the command line of the argument at index, which may be
+// just past the end.
+func (p *Parser) argIndex(index int) int {
+	if p.source.origins == nil {
+		return index
+	}
+	if index < len(p.source.origins) {
+		return p.source.origins[index].index
+	}
+	return len(p.source.args)
+}
+
+// The span of the last part of the command line the parser consumed.
+//
+// Right after Next() this is the option or positional argument it returned.
+// Right after Value(), OptionalValue() or an item from a Values() iterator
+// it's the value. For an ArgLong it covers the name including the dashes
+// but not =value, and for an ArgShort it's the single character inside the
+// cluster.
+//
+// Arg values don't carry their span so that they stay comparable, as in
+// arg == Short{'n'}. Call this right after Next() instead.
+//
+// # Example
+//
+//	arg, ok, err := parser.Next()
+//	if err != nil {
+//	    return err
+//	}
+//	span := parser.Span()
+//	fmt.Printf("%#v at argument %v, bytes %v to %v", arg, span.Index, span.Start, span.End)
+func (p *Parser) Span() Span {
+	return p.span
+}
+
+// Like arg.Unexpected(), but the error records parser.Span().
+//
+// Call it right after Next() returned arg.
+//
+// known are the options the caller does accept, like --verbose and -v.
+// If arg is an unknown long option, the ones that look like a misspelling
+// of it become the error's Suggestions, as by Suggest():
+//
+//	invalid option '--verbsoe'; did you mean '--verbose'?
+func (p *Parser) Unexpected(arg Arg, known ...string) Error {
+	err := arg.Unexpected()
+	span := p.span
+	if e, ok := err.(*ErrorUnexpectedOption); ok {
+		e.Span = &span
+		if _, ok := arg.(ArgLong); ok {
+			var longs []string
+			for _, name := range known {
+				if strings.HasPrefix(name, "--") {
+					longs = append(longs, name)
+				}
+			}
+			e.Suggestions = Suggest(e.A, longs)
+		}
+	} else if e, ok := err.(*ErrorUnexpectedArgument); ok {
+		e.Span = &span
+	}
+	return err
+}
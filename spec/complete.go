@@ -0,0 +1,154 @@
+package spec
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jcbhmr/go-lexopt"
+)
+
+// Find completions for the word under the cursor on a partial command line.
+//
+// words and cword are as for lexopt.ParserFromCompletion(). The command
+// line is parsed as by Parse(), so the candidates are for whichever
+// subcommand is active, and a value is completed wherever Parse() would
+// take one. Candidates come from Complete, Values and Hint, in that order
+// of preference, and options and subcommands are described by their Help.
+//
+// The candidates are not filtered yet: lexopt.WriteCandidates() does that
+// with the prefix in the returned Completion.
+func (c *Command) Complete(words []string, cword int) (lexopt.Completion, []lexopt.Candidate) {
+	p := lexopt.ParserFromCompletion(words, cword)
+	r := newResult(c, nil)
+	// Partial command lines are often invalid. We go as far as we can.
+	c.parse(p, r)
+	completion, ok := p.Completion()
+	if !ok {
+		return completion, nil
+	}
+	for r.Subcommand != nil {
+		r = r.Subcommand
+	}
+	cmd := r.Command
+
+	var candidates []lexopt.Candidate
+	switch completion.Kind {
+	case lexopt.CompleteOption:
+		for i := range cmd.Options {
+			for _, name := range cmd.Options[i].spellings() {
+				candidates = append(candidates, lexopt.Candidate{Value: name, Description: cmd.Options[i].Help})
+			}
+		}
+	case lexopt.CompleteShort:
+		for i := range cmd.Options {
+			if cmd.Options[i].Short != 0 {
+				candidates = append(candidates, lexopt.Candidate{Value: string(cmd.Options[i].Short), Description: cmd.Options[i].Help})
+			}
+		}
+	case lexopt.CompleteValue:
+		for i := range cmd.Options {
+			opt := &cmd.Options[i]
+			if (opt.Kind == Value || opt.Kind == OptionalValue) && (completion.Option == fmt.Sprintf("-%c", opt.Short) || completion.Option == "--"+opt.Long) {
+				candidates = completeValue(opt.Complete, opt.Values, opt.Hint, completion.Prefix)
+			}
+		}
+	case lexopt.CompleteArgument:
+		positional := 0
+		for i := range cmd.Positionals {
+			if len(r.values[cmd.Positionals[i].Name]) > 0 && !cmd.Positionals[i].Multiple {
+				positional = i + 1
+			}
+		}
+		if !completion.AfterDashes && !r.hasPositionals() {
+			for _, sub := range cmd.Subcommands {
+				candidates = append(candidates, lexopt.Candidate{Value: sub.Name, Description: sub.Help})
+			}
+		}
+		if positional < len(cmd.Positionals) {
+			pos := &cmd.Positionals[positional]
+			candidates = append(candidates, completeValue(pos.Complete, pos.Values, pos.Hint, completion.Prefix)...)
+		}
+	}
+	return completion, candidates
+}
+
+// Answer a completion request.
+//
+// This is Complete() followed by lexopt.WriteCandidates(). A program
+// answers requests by checking for them before it parses its command line
+// for real:
+//
+//	if words, cword, ok := lexopt.CompletionRequest(os.Args); ok {
+//	    cmd.WriteCompletions(os.Stdout, words, cword)
+//	    return
+//	}
+//
+// The scripts from WriteBashCompletion(), WriteZshCompletion() and
+// WriteFishCompletion() ask when an option or argument has a Complete
+// function. They pass the words up to the cursor, with bash's --option, =
+// and value words joined back into one, and take off the --option= in
+// front of each candidate, which the shells don't expect.
+func (c *Command) WriteCompletions(w io.Writer, words []string, cword int) error {
+	completion, candidates := c.Complete(words, cword)
+	return lexopt.WriteCandidates(w, completion, candidates)
+}
+
+// Whether any positional argument was given.
+func (r *Result) hasPositionals() bool {
+	for i := range r.Command.Positionals {
+		if len(r.values[r.Command.Positionals[i].Name]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func completeValue(complete func(string) []lexopt.Candidate, values []string, hint Hint, prefix string) []lexopt.Candidate {
+	if complete != nil {
+		return complete(prefix)
+	}
+	if len(values) > 0 {
+		candidates := make([]lexopt.Candidate, len(values))
+		for i, value := range values {
+			candidates[i] = lexopt.Candidate{Value: value}
+		}
+		return candidates
+	}
+	if hint == HintFile || hint == HintDir {
+		return completePath(prefix, hint == HintDir)
+	}
+	return nil
+}
+
+// List the files (or only directories) that could complete prefix.
+// Directories end with a slash so that completion can continue into them.
+func completePath(prefix string, dirsOnly bool) []lexopt.Candidate {
+	dir, base := filepath.Split(prefix)
+	entries, err := os.ReadDir(cmp.Or(dir, "."))
+	if err != nil {
+		return nil
+	}
+	var candidates []lexopt.Candidate
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(cmp.Or(dir, "."), name)); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		if isDir {
+			candidates = append(candidates, lexopt.Candidate{Value: dir + name + "/"})
+		} else if !dirsOnly {
+			candidates = append(candidates, lexopt.Candidate{Value: dir + name})
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,92 @@
+package spec
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// A command together with the names of the commands above it.
+type commandPath struct {
+	*Command
+	path []string
+}
+
+// Every command in the tree, parents before their subcommands.
+func (c *Command) walk() []commandPath {
+	var commands []commandPath
+	var visit func(cp commandPath)
+	visit = func(cp commandPath) {
+		commands = append(commands, cp)
+		for _, sub := range cp.Subcommands {
+			visit(cp.child(sub))
+		}
+	}
+	visit(commandPath{c, []string{c.Name}})
+	return commands
+}
+
+func (cp commandPath) child(sub *Command) commandPath {
+	return commandPath{sub, slices.Concat(cp.path, []string{sub.Name})}
+}
+
+// A name that's safe to use in a shell function name.
+func (cp commandPath) ident() string {
+	parts := make([]string, len(cp.path))
+	for i, name := range cp.path {
+		parts[i] = strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+				return r
+			}
+			return '_'
+		}, name)
+	}
+	return strings.Join(parts, "__")
+}
+
+// Whether any option or positional argument has a Complete function, so
+// that completion scripts have to ask the program with
+// lexopt.CompleteCommand.
+func (c *Command) hasDynamicCompletion() bool {
+	for _, cp := range c.walk() {
+		if cp.hasDynamicPositionals() {
+			return true
+		}
+		for i := range cp.Options {
+			if cp.Options[i].Complete != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Command) hasDynamicPositionals() bool {
+	for i := range c.Positionals {
+		if c.Positionals[i].Complete != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// The ways an option can be written, like -n and --number, and --no-color
+// for a Negatable option.
+func (o *Option) spellings() []string {
+	var names []string
+	if o.Short != 0 {
+		names = append(names, fmt.Sprintf("-%c", o.Short))
+	}
+	if o.Long != "" {
+		names = append(names, "--"+o.Long)
+	}
+	if o.Long != "" && o.Kind == Negatable {
+		names = append(names, "--no-"+o.Long)
+	}
+	return names
+}
+
+// Quote a string for a POSIX shell, always with single quotes.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,216 @@
+package spec
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jcbhmr/go-lexopt"
+)
+
+// Write a bash completion script for the command.
+//
+// The script defines a completion function and registers it for c.Name
+// with complete -F. It completes options, subcommands at any depth, and
+// values of options and positional arguments from their Values and Hint.
+// Those with a Complete function are completed by running the program with
+// lexopt.CompleteCommand, so it has to answer with WriteCompletions().
+// Install it by sourcing it, or by putting it in bash-completion's
+// completions directory.
+func (c *Command) WriteBashCompletion(w io.Writer) error {
+	commands := c.walk()
+	root := commands[0]
+	dynamic := fmt.Sprintf("_%v_dynamic", root.ident())
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %v\n\n", c.Name)
+	if c.hasDynamicCompletion() {
+		writeBashDynamic(&b, dynamic)
+	}
+	fmt.Fprintf(&b, "_%v() {\n", root.ident())
+	b.WriteString("    local cur prev cmd word i\n")
+	b.WriteString("    COMPREPLY=()\n")
+	b.WriteString("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("    prev=\"\"\n")
+	b.WriteString("    if ((COMP_CWORD > 0)); then\n")
+	b.WriteString("        prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	b.WriteString("    fi\n")
+	b.WriteString("    # bash splits --option=value into three words.\n")
+	b.WriteString("    if [[ $cur == \"=\" ]]; then\n")
+	b.WriteString("        cur=\"\"\n")
+	b.WriteString("    elif [[ $prev == \"=\" ]] && ((COMP_CWORD > 1)); then\n")
+	b.WriteString("        prev=\"${COMP_WORDS[COMP_CWORD-2]}\"\n")
+	b.WriteString("    fi\n\n")
+
+	// Find the subcommand, skipping the values of options.
+	fmt.Fprintf(&b, "    cmd=%v\n", shellQuote(root.ident()))
+	b.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	b.WriteString("        word=\"${COMP_WORDS[i]}\"\n")
+	b.WriteString("        case \"$cmd:$word\" in\n")
+	for _, cp := range commands {
+		if patterns := cp.bashOptionPatterns(func(o *Option) bool { return o.Kind == Value }); patterns != "" {
+			fmt.Fprintf(&b, "            %v)\n", patterns)
+			b.WriteString("                if [[ ${COMP_WORDS[i+1]} == \"=\" ]]; then ((i += 2)); else ((i++)); fi ;;\n")
+		}
+		if patterns := cp.bashOptionPatterns(func(o *Option) bool { return o.Kind == OptionalValue }); patterns != "" {
+			fmt.Fprintf(&b, "            %v)\n", patterns)
+			b.WriteString("                if [[ ${COMP_WORDS[i+1]} == \"=\" ]]; then ((i += 2)); fi ;;\n")
+		}
+		for _, sub := range cp.Subcommands {
+			fmt.Fprintf(&b, "            %v) cmd=%v ;;\n", shellQuote(cp.ident()+":"+sub.Name), shellQuote(cp.child(sub).ident()))
+		}
+	}
+	b.WriteString("        esac\n")
+	b.WriteString("    done\n\n")
+
+	// Values of options.
+	b.WriteString("    case \"$cmd:$prev\" in\n")
+	for _, cp := range commands {
+		for i := range cp.Options {
+			opt := &cp.Options[i]
+			if opt.Kind != Value {
+				continue
+			}
+			patterns := cp.bashOptionPatterns(func(o *Option) bool { return o == opt })
+			fmt.Fprintf(&b, "        %v)\n", patterns)
+			fmt.Fprintf(&b, "            %v\n", opt.bashComplete(dynamic))
+			b.WriteString("            return ;;\n")
+		}
+	}
+	b.WriteString("    esac\n\n")
+
+	// Optional values, only after =.
+	var optional strings.Builder
+	for _, cp := range commands {
+		for i := range cp.Options {
+			opt := &cp.Options[i]
+			if opt.Kind != OptionalValue || opt.Long == "" || len(opt.Values) == 0 && opt.Hint == HintNone && opt.Complete == nil {
+				continue
+			}
+			fmt.Fprintf(&optional, "            %v)\n", shellQuote(cp.ident()+":--"+opt.Long))
+			fmt.Fprintf(&optional, "                %v\n", opt.bashComplete(dynamic))
+			optional.WriteString("                return ;;\n")
+		}
+	}
+	if optional.Len() > 0 {
+		b.WriteString("    if [[ ${COMP_WORDS[COMP_CWORD]} == \"=\" || ${COMP_WORDS[COMP_CWORD-1]} == \"=\" ]]; then\n")
+		b.WriteString("        case \"$cmd:$prev\" in\n")
+		b.WriteString(optional.String())
+		b.WriteString("        esac\n")
+		b.WriteString("    fi\n\n")
+	}
+
+	// Options.
+	b.WriteString("    if [[ $cur == -* ]]; then\n")
+	b.WriteString("        case \"$cmd\" in\n")
+	for _, cp := range commands {
+		var words []string
+		for i := range cp.Options {
+			words = append(words, cp.Options[i].spellings()...)
+		}
+		if len(words) > 0 {
+			fmt.Fprintf(&b, "            %v) COMPREPLY=($(compgen -W %v -- \"$cur\")) ;;\n", shellQuote(cp.ident()), shellQuote(strings.Join(words, " ")))
+		}
+	}
+	b.WriteString("        esac\n")
+	b.WriteString("        return\n")
+	b.WriteString("    fi\n\n")
+
+	// Subcommands and positional arguments.
+	b.WriteString("    case \"$cmd\" in\n")
+	for _, cp := range commands {
+		var words []string
+		var hint Hint
+		for _, sub := range cp.Subcommands {
+			words = append(words, sub.Name)
+		}
+		for i := range cp.Positionals {
+			words = append(words, cp.Positionals[i].Values...)
+			hint = max(hint, cp.Positionals[i].Hint)
+		}
+		if len(words) == 0 && hint == HintNone && !cp.hasDynamicPositionals() {
+			continue
+		}
+		fmt.Fprintf(&b, "        %v)\n", shellQuote(cp.ident()))
+		if cp.hasDynamicPositionals() {
+			// The program knows the subcommands too.
+			fmt.Fprintf(&b, "            %v\n", dynamic)
+		} else {
+			fmt.Fprintf(&b, "            %v\n", bashComplete(words, hint))
+		}
+		b.WriteString("            ;;\n")
+	}
+	b.WriteString("    esac\n")
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "complete -F _%v %v\n", root.ident(), shellQuote(c.Name))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Write a function that fills in COMPREPLY by asking the program, for
+// options and arguments with a Complete function.
+//
+// The program gets the words up to the cursor, with --option=value in one
+// word again, and puts what comes before the value in front of each
+// candidate. bash only replaces the part after the =, so that's taken off.
+// It's called from the main function, whose cur it uses.
+func writeBashDynamic(b *strings.Builder, name string) {
+	fmt.Fprintf(b, "%v() {\n", name)
+	b.WriteString("    local words=() word before i\n")
+	b.WriteString("    # Rejoin --option=value, which bash splits into three words.\n")
+	b.WriteString("    for ((i = 0; i <= COMP_CWORD; i++)); do\n")
+	b.WriteString("        word=\"${COMP_WORDS[i]}\"\n")
+	b.WriteString("        if ((i > 1)) && [[ $word == \"=\" && ${COMP_WORDS[i-1]} == -* || ${COMP_WORDS[i-1]} == \"=\" && ${COMP_WORDS[i-2]} == -* ]]; then\n")
+	b.WriteString("            words[${#words[@]}-1]+=\"$word\"\n")
+	b.WriteString("        else\n")
+	b.WriteString("            words+=(\"$word\")\n")
+	b.WriteString("        fi\n")
+	b.WriteString("    done\n")
+	b.WriteString("    # bash only replaces what's after the =.\n")
+	b.WriteString("    before=\"${words[${#words[@]}-1]}\"\n")
+	b.WriteString("    before=\"${before:0:${#before}-${#cur}}\"\n")
+	b.WriteString("    while IFS= read -r word; do\n")
+	b.WriteString("        COMPREPLY+=(\"${word#\"$before\"}\")\n")
+	fmt.Fprintf(b, "    done < <(\"${COMP_WORDS[0]}\" %v \"$((${#words[@]} - 1))\" \"${words[@]}\" 2>/dev/null | cut -f1)\n", lexopt.CompleteCommand)
+	b.WriteString("}\n\n")
+}
+
+// A command that fills in COMPREPLY for the value of an option.
+func (o *Option) bashComplete(dynamic string) string {
+	if o.Complete != nil {
+		return dynamic
+	}
+	return bashComplete(o.Values, o.Hint)
+}
+
+// A case pattern that matches "cmd:-o" and "cmd:--option" for the options
+// that match.
+func (cp commandPath) bashOptionPatterns(match func(*Option) bool) string {
+	var patterns []string
+	for i := range cp.Options {
+		if match(&cp.Options[i]) {
+			for _, name := range cp.Options[i].spellings() {
+				patterns = append(patterns, shellQuote(cp.ident()+":"+name))
+			}
+		}
+	}
+	return strings.Join(patterns, " | ")
+}
+
+// A command that fills in COMPREPLY from a list of words and a hint.
+func bashComplete(words []string, hint Hint) string {
+	var parts []string
+	if len(words) > 0 {
+		parts = append(parts, fmt.Sprintf("COMPREPLY+=($(compgen -W %v -- \"$cur\"))", shellQuote(strings.Join(words, " "))))
+	}
+	switch hint {
+	case HintFile:
+		parts = append(parts, "compopt -o filenames 2>/dev/null", "COMPREPLY+=($(compgen -f -- \"$cur\"))")
+	case HintDir:
+		parts = append(parts, "compopt -o filenames 2>/dev/null", "COMPREPLY+=($(compgen -d -- \"$cur\"))")
+	}
+	if len(parts) == 0 {
+		return ":"
+	}
+	return strings.Join(parts, "; ")
+}
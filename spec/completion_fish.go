@@ -0,0 +1,154 @@
+package spec
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/jcbhmr/go-lexopt"
+)
+
+// Write a fish completion script for the command.
+//
+// The script is a list of complete commands, with conditions that select
+// the right options for each subcommand. Install it as NAME.fish in a
+// directory in $fish_complete_path, or source it.
+//
+// Values of options and arguments with a Complete function are completed
+// by running the program with lexopt.CompleteCommand, so the program has
+// to answer it with Command.WriteCompletions().
+func (c *Command) WriteFishCompletion(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %v\n\n", c.Name)
+	// Only complete files where a file is expected.
+	fmt.Fprintf(&b, "complete -c %v -f\n", shellQuote(c.Name))
+	walk := c.walk()
+	dynamic := fmt.Sprintf("__%v_dynamic", walk[0].ident())
+	if c.hasDynamicCompletion() {
+		b.WriteString("\n")
+		writeFishDynamic(&b, dynamic)
+	}
+	for _, cp := range walk {
+		cond := cp.fishCondition()
+		b.WriteString("\n")
+		for i := range cp.Options {
+			opt := &cp.Options[i]
+			parts := []string{"complete", "-c", shellQuote(c.Name)}
+			if cond != "" {
+				parts = append(parts, "-n", shellQuote(cond))
+			}
+			if opt.Short != 0 {
+				parts = append(parts, "-s", shellQuote(string(opt.Short)))
+			}
+			if opt.Long != "" {
+				parts = append(parts, "-l", shellQuote(opt.Long))
+			}
+			if opt.Long != "" && opt.Kind == Negatable {
+				parts = append(parts, "-l", shellQuote("no-"+opt.Long))
+			}
+			if opt.Complete != nil && opt.Kind == Value {
+				parts = append(parts, "-x", "-a", shellQuote("("+dynamic+")"))
+			} else if opt.Complete != nil && opt.Kind == OptionalValue {
+				parts = append(parts, "-f", "-a", shellQuote("("+dynamic+")"))
+			} else if opt.Kind == Value {
+				parts = append(parts, fishValue(opt.Values, opt.Hint, "-r", "-x")...)
+			} else if opt.Kind == OptionalValue {
+				parts = append(parts, fishValue(opt.Values, opt.Hint, "", "-f")...)
+			}
+			if opt.Help != "" {
+				parts = append(parts, "-d", shellQuote(opt.Help))
+			}
+			b.WriteString(strings.Join(parts, " ") + "\n")
+		}
+		for _, sub := range cp.Subcommands {
+			parts := []string{"complete", "-c", shellQuote(c.Name)}
+			if cond != "" {
+				parts = append(parts, "-n", shellQuote(cond))
+			}
+			parts = append(parts, "-a", shellQuote(sub.Name))
+			if sub.Help != "" {
+				parts = append(parts, "-d", shellQuote(sub.Help))
+			}
+			b.WriteString(strings.Join(parts, " ") + "\n")
+		}
+		if len(cp.Subcommands) > 0 {
+			continue
+		}
+		for i := range cp.Positionals {
+			pos := &cp.Positionals[i]
+			value := fishValue(pos.Values, pos.Hint, "", "")
+			if pos.Complete != nil {
+				value = []string{"-a", shellQuote("(" + dynamic + ")")}
+			}
+			if len(value) == 0 {
+				continue
+			}
+			parts := []string{"complete", "-c", shellQuote(c.Name)}
+			if cond != "" {
+				parts = append(parts, "-n", shellQuote(cond))
+			}
+			parts = append(parts, value...)
+			if pos.Help != "" {
+				parts = append(parts, "-d", shellQuote(pos.Help))
+			}
+			b.WriteString(strings.Join(parts, " ") + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Write a function that prints candidates by asking the program, for
+// options and arguments with a Complete function.
+//
+// The program puts what comes before the value, like --option=, in front
+// of each candidate. fish only completes the value, so that's taken off.
+// The descriptions after the tab are kept, since fish reads them the same
+// way.
+func writeFishDynamic(b *strings.Builder, name string) {
+	fmt.Fprintf(b, "function %v\n", name)
+	b.WriteString("    set -l cur (commandline -ct)\n")
+	b.WriteString("    set -l words (commandline -opc) \"$cur\"\n")
+	b.WriteString("    set -l before (string match -r -- '^--[^=]*=' \"$cur\")\n")
+	fmt.Fprintf(b, "    for line in ($words[1] %v (math (count $words) - 1) $words 2>/dev/null)\n", lexopt.CompleteCommand)
+	b.WriteString("        string sub -s (math (string length -- \"$before\") + 1) -- $line\n")
+	b.WriteString("    end\n")
+	b.WriteString("end\n")
+}
+
+// The condition under which a command's options and arguments apply: all
+// of its parents' subcommands have been seen, and none of its own have.
+func (cp commandPath) fishCondition() string {
+	var conds []string
+	for _, name := range cp.path[1:] {
+		conds = append(conds, "__fish_seen_subcommand_from "+name)
+	}
+	if len(cp.Subcommands) > 0 {
+		conds = append(conds, "not __fish_seen_subcommand_from "+strings.Join(cp.subcommandNames(), " "))
+	}
+	return strings.Join(conds, "; and ")
+}
+
+// The flags of a complete command that complete a value. required and
+// exclusive are the flags that mark the value as required, and as required
+// and not a file. They're empty for positional arguments.
+func fishValue(values []string, hint Hint, required string, exclusive string) []string {
+	var parts []string
+	switch hint {
+	case HintFile:
+		parts = append(parts, required, "-F")
+	case HintDir:
+		parts = append(parts, exclusive, "-a", "'(__fish_complete_directories)'")
+	default:
+		parts = append(parts, exclusive)
+	}
+	if len(values) > 0 {
+		parts = append(parts, "-a", shellQuote(strings.Join(values, " ")))
+	}
+	parts = slices.DeleteFunc(parts, func(part string) bool { return part == "" })
+	if hint == HintNone && len(values) == 0 && required == "" {
+		return nil
+	}
+	return parts
+}
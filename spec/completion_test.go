@@ -0,0 +1,201 @@
+package spec
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jcbhmr/go-lexopt"
+	"github.com/stretchr/testify/require"
+)
+
+var vcs = &Command{
+	Name: "vcs",
+	Help: "A version control system",
+	Description: `vcs tracks changes to files.
+
+It's only a test, but --color and the like work as usual.
+
+.vcs directories are where it keeps its data.`,
+	ExitStatus: []ExitStatus{
+		{0, "Success."},
+		{1, "Something went wrong."},
+		{128, "The repository is *broken*."},
+	},
+	Options: []Option{
+		{Short: 'C', Kind: Value, ValueName: "DIR", Hint: HintDir, Help: "Run as if started in DIR"},
+		{Long: "color", Kind: Value, ValueName: "WHEN", Values: []string{"always", "never", "auto"}, Help: "When to use colors"},
+		{Short: 'v', Long: "verbose", Kind: Flag, Help: "Say more: about what's happening"},
+	},
+	Subcommands: []*Command{
+		{
+			Name: "commit",
+			Help: "Record changes",
+			Options: []Option{
+				{Short: 'm', Long: "message", Kind: Value, ValueName: "MSG", Help: "Use MSG as the commit message"},
+				{Short: 'F', Long: "file", Kind: Value, ValueName: "FILE", Hint: HintFile, Help: "Take the message from FILE"},
+				{Long: "fixup", Kind: Value, ValueName: "COMMIT", Help: "Fix up COMMIT", Complete: func(prefix string) []lexopt.Candidate {
+					return []lexopt.Candidate{{Value: "HEAD", Description: "The current commit"}, {Value: "HEAD~1"}, {Value: "main", Description: "Branch"}}
+				}},
+				{Short: 'S', Long: "gpg-sign", Kind: OptionalValue, ValueName: "KEYID", Help: "Sign the commit"},
+				{Long: "cleanup", Kind: OptionalValue, ValueName: "MODE", Values: []string{"strip", "whitespace", "verbatim"}, Implicit: "strip", Help: "Clean up the message"},
+			},
+			Positionals: []Positional{
+				{Name: "PATHSPEC", Multiple: true, Hint: HintFile},
+			},
+		},
+		{
+			Name: "remote",
+			Help: "Manage remotes",
+			Subcommands: []*Command{
+				{
+					Name:        "add",
+					Help:        "Add a remote",
+					Options:     []Option{{Short: 'f', Long: "fetch", Kind: Flag, Help: "Fetch after adding"}},
+					Positionals: []Positional{{Name: "NAME", Required: true}, {Name: "URL", Required: true}},
+				},
+				{
+					Name:        "remove",
+					Help:        "Remove a remote",
+					Positionals: []Positional{{Name: "NAME", Required: true, Values: []string{"origin", "upstream"}}},
+				},
+			},
+		},
+	},
+}
+
+// The bash tests run the test binary as vcs, to answer completion
+// requests.
+func TestMain(m *testing.M) {
+	if os.Getenv("SPEC_TEST_VCS") != "" {
+		if words, cword, ok := lexopt.CompletionRequest(os.Args); ok {
+			vcs.WriteCompletions(os.Stdout, words, cword)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestCompletion(t *testing.T) {
+	for _, c := range []*Command{hello, cargo, vcs} {
+		var bash, zsh, fish strings.Builder
+		require.Nil(t, c.WriteBashCompletion(&bash))
+		require.Nil(t, c.WriteZshCompletion(&zsh))
+		require.Nil(t, c.WriteFishCompletion(&fish))
+		golden(t, "completion/"+c.Name+".bash", bash.String())
+		golden(t, "completion/_"+c.Name, zsh.String())
+		golden(t, "completion/"+c.Name+".fish", fish.String())
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+	var script strings.Builder
+	require.Nil(t, vcs.WriteBashCompletion(&script))
+	scriptPath := filepath.Join(t.TempDir(), "vcs.bash")
+	require.Nil(t, os.WriteFile(scriptPath, []byte(script.String()), 0o666))
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o666))
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "src"), 0o777))
+	bin := t.TempDir()
+	test, err := os.Executable()
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(filepath.Join(bin, "vcs"), []byte("#!/bin/sh\nSPEC_TEST_VCS=1 exec "+shellQuote(test)+" \"$@\"\n"), 0o777))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	// Complete the last of words, as bash would split them.
+	complete := func(words ...string) []string {
+		t.Helper()
+		cmd := exec.Command(bash, "--norc", "--noprofile", "-c", `
+			source "$0"
+			COMP_WORDS=("$@")
+			COMP_CWORD=$(($# - 1))
+			_vcs
+			printf '%s\n' "${COMPREPLY[@]}" | sort`, scriptPath)
+		cmd.Args = append(cmd.Args, words...)
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		require.Nil(t, err)
+		return strings.Fields(string(out))
+	}
+
+	require.Equal(t, []string{"commit", "remote"}, complete("vcs", ""))
+	require.Equal(t, []string{"--color", "--verbose", "-C", "-v"}, complete("vcs", "-"))
+	require.Equal(t, []string{"--color"}, complete("vcs", "--c"))
+	require.Equal(t, []string{"always", "auto", "never"}, complete("vcs", "--color", ""))
+	require.Equal(t, []string{"always", "auto"}, complete("vcs", "--color", "=", "a"))
+	require.Equal(t, []string{"src"}, complete("vcs", "-C", ""))
+	require.Equal(t, []string{"commit", "remote"}, complete("vcs", "-C", "src", ""))
+	require.Equal(t, []string{"--cleanup", "--file", "--fixup", "--gpg-sign", "--message", "-F", "-S", "-m"}, complete("vcs", "-v", "commit", "-"))
+	require.Equal(t, []string{"notes.txt", "src"}, complete("vcs", "commit", "-F", ""))
+	require.Equal(t, []string{"notes.txt"}, complete("vcs", "commit", "-m", "msg", "n"))
+	require.Equal(t, []string{"add", "remove"}, complete("vcs", "remote", ""))
+	require.Equal(t, []string{"--fetch", "-f"}, complete("vcs", "remote", "add", "-"))
+	require.Equal(t, []string{"origin"}, complete("vcs", "remote", "remove", "o"))
+	// The message isn't mistaken for the remote subcommand.
+	require.Equal(t, []string{"notes.txt", "src"}, complete("vcs", "commit", "-m", "remote", ""))
+	// Optional values are only completed after =.
+	require.Equal(t, []string{"strip", "verbatim", "whitespace"}, complete("vcs", "commit", "--cleanup", "=", ""))
+	require.Equal(t, []string{"verbatim"}, complete("vcs", "commit", "--cleanup", "=", "v"))
+	require.Equal(t, []string{"notes.txt", "src"}, complete("vcs", "commit", "--cleanup", ""))
+	require.Equal(t, []string{"notes.txt", "src"}, complete("vcs", "commit", "--cleanup", "=", "strip", ""))
+	// Complete functions are asked through vcs __complete, with the value
+	// of --option=value alone.
+	require.Equal(t, []string{"HEAD", "HEAD~1"}, complete("vcs", "commit", "--fixup", "H"))
+	require.Equal(t, []string{"main"}, complete("vcs", "-v", "commit", "--fixup", "=", "m"))
+	require.Equal(t, []string{"HEAD", "HEAD~1", "main"}, complete("vcs", "commit", "--fixup", "="))
+	require.Equal(t, []string{"notes.txt", "src"}, complete("vcs", "commit", "--fixup", "=", "main", ""))
+}
+
+func TestComplete(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o666))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, ".hidden"), nil, 0o666))
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "src"), 0o777))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "src", "main.go"), nil, 0o666))
+	wd, err := os.Getwd()
+	require.Nil(t, err)
+	require.Nil(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	// Complete the last of words.
+	complete := func(words ...string) string {
+		t.Helper()
+		var b strings.Builder
+		require.Nil(t, vcs.WriteCompletions(&b, words, len(words)-1))
+		return b.String()
+	}
+
+	require.Equal(t, "commit\tRecord changes\nremote\tManage remotes\n", complete("vcs", ""))
+	require.Equal(t, "remote\tManage remotes\n", complete("vcs", "-v", "r"))
+	require.Equal(t, "--color\tWhen to use colors\n--verbose\tSay more: about what's happening\n", complete("vcs", "--"))
+	require.Equal(t, "-vC\tRun as if started in DIR\n-vv\tSay more: about what's happening\n", complete("vcs", "-v"))
+	require.Equal(t, "always\nauto\n", complete("vcs", "--color", "a"))
+	require.Equal(t, "--color=never\n", complete("vcs", "--color=n"))
+	require.Equal(t, "src/\n", complete("vcs", "-C", ""))
+	require.Equal(t, "-Csrc/\n", complete("vcs", "-Cs"))
+	require.Equal(t, "commit\tRecord changes\nremote\tManage remotes\n", complete("vcs", "-C", "src", ""))
+
+	require.Equal(t, "HEAD\tThe current commit\nHEAD~1\n", complete("vcs", "commit", "--fixup", "H"))
+	require.Equal(t, "--fixup=main\tBranch\n", complete("vcs", "commit", "--fixup=m"))
+	require.Equal(t, "notes.txt\nsrc/\n", complete("vcs", "commit", "-m", "remote", ""))
+	require.Equal(t, "src/main.go\n", complete("vcs", "commit", "src/"))
+	require.Equal(t, ".hidden\n", complete("vcs", "commit", "."))
+	require.Equal(t, "notes.txt\n", complete("vcs", "commit", "--", "n"))
+	require.Equal(t, "--cleanup=whitespace\n", complete("vcs", "commit", "--cleanup=w"))
+	require.Equal(t, "notes.txt\n", complete("vcs", "commit", "--cleanup", "n"))
+
+	require.Equal(t, "add\tAdd a remote\nremove\tRemove a remote\n", complete("vcs", "remote", ""))
+	require.Equal(t, "--fetch\tFetch after adding\n", complete("vcs", "remote", "add", "--"))
+	require.Equal(t, "origin\nupstream\n", complete("vcs", "remote", "remove", ""))
+	// Only one NAME.
+	require.Equal(t, "", complete("vcs", "remote", "remove", "origin", ""))
+
+	// Parsing stopped before the cursor.
+	require.Equal(t, "", complete("vcs", "--bogus", ""))
+}
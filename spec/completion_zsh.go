@@ -0,0 +1,191 @@
+package spec
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jcbhmr/go-lexopt"
+)
+
+// Write a zsh completion script for the command.
+//
+// The script is a completion function for zsh's compsys, built on
+// _arguments, with a function per subcommand. Options and arguments with
+// a Complete function are completed by running the program with
+// lexopt.CompleteCommand, as for WriteBashCompletion(). Install it as a
+// file named _NAME somewhere in $fpath, or source it after compinit.
+func (c *Command) WriteZshCompletion(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %v\n", c.Name)
+	commands := c.walk()
+	dynamic := fmt.Sprintf("_%v_dynamic", commands[0].ident())
+	words := fmt.Sprintf("_%v_words", commands[0].ident())
+	if c.hasDynamicCompletion() {
+		b.WriteString("\n")
+		writeZshDynamic(&b, dynamic, words)
+	}
+	for _, cp := range commands {
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "_%v() {\n", cp.ident())
+		if len(cp.path) == 1 && c.hasDynamicCompletion() {
+			fmt.Fprintf(&b, "    local -a %v\n", words)
+			fmt.Fprintf(&b, "    %v=(\"${(@Q)words[1,CURRENT]}\")\n", words)
+		}
+		cp.writeZshFunction(&b, dynamic)
+	}
+	// Autoloaded from $fpath, or sourced.
+	b.WriteString("\nif [[ $zsh_eval_context[-1] == loadautofunc ]]; then\n")
+	fmt.Fprintf(&b, "    _%v \"$@\"\n", commands[0].ident())
+	b.WriteString("else\n")
+	fmt.Fprintf(&b, "    compdef _%v %v\n", commands[0].ident(), shellQuote(c.Name))
+	b.WriteString("fi\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Write a function that adds candidates by asking the program, for options
+// and arguments with a Complete function.
+//
+// The program gets the words up to the cursor, as the top-level function
+// saved them before _arguments narrowed $words down for a subcommand. It
+// puts what comes before the value, like --option=, in front of each
+// candidate, and that's taken off since _arguments has already moved it
+// to IPREFIX.
+func writeZshDynamic(b *strings.Builder, name string, words string) {
+	fmt.Fprintf(b, "%v() {\n", name)
+	b.WriteString("    local -a candidates\n")
+	b.WriteString("    local line before tab=$'\\t'\n")
+	fmt.Fprintf(b, "    before=${%v[-1]%%$PREFIX}\n", words)
+	fmt.Fprintf(b, "    for line in \"${(@f)$($%v[1] %v $((${#%v} - 1)) \"$%v[@]\" 2>/dev/null)}\"; do\n", words, lexopt.CompleteCommand, words, words)
+	b.WriteString("        [[ -n $line ]] && candidates+=(\"${${line%%$tab*}#$before}\")\n")
+	b.WriteString("    done\n")
+	b.WriteString("    compadd -a candidates\n")
+	b.WriteString("}\n")
+}
+
+// The body of a command's function, after its opening line.
+func (cp commandPath) writeZshFunction(b *strings.Builder, dynamic string) {
+	if len(cp.Subcommands) > 0 {
+		b.WriteString("    local curcontext=\"$curcontext\" state line\n")
+		b.WriteString("    typeset -A opt_args\n")
+	}
+	var specs []string
+	for i := range cp.Options {
+		specs = append(specs, cp.Options[i].zshSpec(dynamic))
+	}
+	if len(cp.Subcommands) > 0 {
+		specs = append(specs, "': :->command'", "'*:: :->args'")
+	} else {
+		for i := range cp.Positionals {
+			specs = append(specs, cp.Positionals[i].zshSpec(dynamic))
+		}
+	}
+	if len(cp.Subcommands) > 0 {
+		b.WriteString("    _arguments -C -s")
+	} else {
+		b.WriteString("    _arguments -s")
+	}
+	for _, spec := range specs {
+		b.WriteString(" \\\n        " + spec)
+	}
+	b.WriteString("\n")
+
+	if len(cp.Subcommands) > 0 {
+		b.WriteString("    case $state in\n")
+		b.WriteString("        command)\n")
+		b.WriteString("            local -a commands\n")
+		b.WriteString("            commands=(\n")
+		for _, sub := range cp.Subcommands {
+			fmt.Fprintf(b, "                %v\n", shellQuote(zshEscape(sub.Name)+":"+sub.Help))
+		}
+		b.WriteString("            )\n")
+		b.WriteString("            _describe -t commands 'command' commands ;;\n")
+		b.WriteString("        args)\n")
+		b.WriteString("            case $line[1] in\n")
+		for _, sub := range cp.Subcommands {
+			fmt.Fprintf(b, "                %v) _%v ;;\n", shellQuote(sub.Name), cp.child(sub).ident())
+		}
+		b.WriteString("            esac ;;\n")
+		b.WriteString("    esac\n")
+	}
+	b.WriteString("}\n")
+}
+
+// An _arguments spec for an option, like
+// '*'{-n+,--number=}'[How many]:NUM: '. dynamic is the function that asks
+// the program, for a Complete function.
+func (o *Option) zshSpec(dynamic string) string {
+	var names []string
+	for _, name := range o.spellings() {
+		if o.Kind == Value && strings.HasPrefix(name, "--") {
+			name += "="
+		} else if o.Kind == Value {
+			name += "+"
+		} else if o.Kind == OptionalValue && strings.HasPrefix(name, "--") {
+			// The value can only be in the same word.
+			name += "=-"
+		} else if o.Kind == OptionalValue {
+			name += "-"
+		}
+		names = append(names, name)
+	}
+	rest := ""
+	if o.Help != "" {
+		rest += "[" + zshEscapeHelp(o.Help) + "]"
+	}
+	action := zshAction(o.Values, o.Hint)
+	if o.Complete != nil {
+		action = dynamic
+	}
+	if o.Kind == Value {
+		rest += ":" + zshEscape(o.valueName()) + ":" + action
+	} else if o.Kind == OptionalValue {
+		rest += "::" + zshEscape(o.valueName()) + ":" + action
+	}
+	// Options can be repeated, so they're marked with * and don't exclude
+	// each other.
+	if len(names) == 1 {
+		return shellQuote("*" + names[0] + rest)
+	}
+	return "'*'{" + strings.Join(names, ",") + "}" + shellQuote(rest)
+}
+
+// An _arguments spec for a positional argument.
+func (p *Positional) zshSpec(dynamic string) string {
+	prefix := ":"
+	if p.Multiple {
+		prefix = "*:"
+	} else if !p.Required {
+		prefix = "::"
+	}
+	action := zshAction(p.Values, p.Hint)
+	if p.Complete != nil {
+		action = dynamic
+	}
+	return shellQuote(prefix + zshEscape(p.Name) + ":" + action)
+}
+
+func zshAction(values []string, hint Hint) string {
+	if len(values) > 0 {
+		return "(" + strings.Join(values, " ") + ")"
+	}
+	switch hint {
+	case HintFile:
+		return "_files"
+	case HintDir:
+		return "_files -/"
+	}
+	return " "
+}
+
+// Escape a name or message for an _arguments spec, where colons separate
+// the parts.
+func zshEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `:`, `\:`).Replace(s)
+}
+
+// Escape the description of an option, which is in square brackets.
+func zshEscapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
@@ -0,0 +1,379 @@
+package spec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jcbhmr/go-lexopt"
+)
+
+// Settings from configuration files, by section and then by option key.
+// The top-level command's section is "", and a subcommand's is its path
+// below it, like "remote.add".
+type configSettings struct {
+	sections map[string]map[string][]configValue
+}
+
+// One value from a configuration file.
+type configValue struct {
+	value string
+	path  string
+	line  int
+}
+
+func (s *configSettings) get(section string, key string) []configValue {
+	if s == nil {
+		return nil
+	}
+	return s.sections[section][key]
+}
+
+// Layer other on top: every key it has replaces the same key in s.
+func (s *configSettings) merge(other *configSettings) {
+	for section, keys := range other.sections {
+		if s.sections[section] == nil {
+			s.sections[section] = map[string][]configValue{}
+		}
+		for key, values := range keys {
+			s.sections[section][key] = values
+		}
+	}
+}
+
+func (s *configSettings) add(section string, key string, v configValue) {
+	if s.sections[section] == nil {
+		s.sections[section] = map[string][]configValue{}
+	}
+	s.sections[section][key] = append(s.sections[section][key], v)
+}
+
+// Read the configuration files for the command: ConfigFiles, then the
+// files named by ConfigOption on the command line.
+func (c *Command) loadConfig(p *lexopt.Parser) (*configSettings, lexopt.Error) {
+	if len(c.ConfigFiles) == 0 && c.ConfigOption == "" {
+		return nil, nil
+	}
+	config := &configSettings{sections: map[string]map[string][]configValue{}}
+	for _, path := range c.ConfigFiles {
+		settings, err := c.readConfig(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, &lexopt.ErrorCustom{A: err}
+		}
+		config.merge(settings)
+	}
+	if c.ConfigOption == "" {
+		return config, nil
+	}
+	return config, c.scanConfigOption(p.Clone(), func(path string, p *lexopt.Parser) lexopt.Error {
+		settings, err := c.readConfig(path)
+		if err != nil {
+			return p.ParsingFailed(path, err)
+		}
+		config.merge(settings)
+		return nil
+	})
+}
+
+// Find the values of ConfigOption on the command line, up to the first
+// subcommand, and call load for each with p positioned just after it.
+//
+// p is tokenised as parse() would, so the value of some other option is
+// never mistaken for ConfigOption. Other errors are left for parse() to
+// report.
+func (c *Command) scanConfigOption(p *lexopt.Parser, load func(path string, p *lexopt.Parser) lexopt.Error) lexopt.Error {
+	for {
+		arg, ok, err := p.Next()
+		if err != nil {
+			continue
+		}
+		if !ok {
+			return nil
+		}
+		var option *Option
+		if short, ok := arg.(lexopt.ArgShort); ok {
+			option = c.lookupShort(short.A)
+		} else if long, ok := arg.(lexopt.ArgLong); ok {
+			option = c.lookupLong(long.A)
+			if option == nil {
+				option = c.lookupNegated(long.A)
+			}
+		} else if value, ok := arg.(lexopt.ArgValue); ok {
+			if c.lookupSubcommand(value.A) != nil {
+				return nil
+			}
+			continue
+		}
+		if option == nil || option.Kind != Value {
+			// Whatever it is, an attached value isn't an option.
+			p.OptionalValue()
+			continue
+		}
+		value, err := p.Value()
+		if err != nil {
+			return nil
+		}
+		if option.key() == c.ConfigOption {
+			if err := load(value, p); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Fill in the options that weren't on the command line or in the
+// environment from the configuration files.
+func (c *Command) parseConfig(r *Result) lexopt.Error {
+	for i := range c.Options {
+		option := &c.Options[i]
+		if len(r.values[option.key()]) > 0 {
+			continue
+		}
+		for _, v := range r.config.get(r.section, option.key()) {
+			o := occurrence{
+				option: fmt.Sprintf("%v:%v: %v", v.path, v.line, option.key()),
+				from:   Source{Kind: SourceConfig, Path: v.path, Line: v.line},
+			}
+			if err := addSetting(r, option, o, v.value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Read a configuration file for the command and check that it only has
+// settings the command knows about.
+//
+// Files ending in .json are JSON: an object whose keys are option keys,
+// with strings, numbers or booleans as values, or arrays of them for
+// options given more than once. A key that's the name of a subcommand
+// has an object with that subcommand's settings.
+//
+//	{"jobs": 8, "include": ["a", "b"], "install": {"root": "/opt"}}
+//
+// Anything else is INI: key = value lines, optionally in sections for
+// subcommands, like [install] or [remote.add]. Repeating a key gives the
+// option several values. Values can be quoted as Go strings. # and ;
+// start comments, at the start of a line or after whitespace. This is the
+// format that result.WriteConfig() writes.
+//
+//	jobs = 8
+//	include = a
+//	include = b
+//
+//	[install]
+//	root = /opt
+//
+// Errors name the file, and the line if it's about one.
+func (c *Command) readConfig(path string) (*configSettings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	settings := &configSettings{sections: map[string]map[string][]configValue{}}
+	if strings.HasSuffix(path, ".json") {
+		err = parseJSONConfig(settings, path, data)
+	} else {
+		err = parseINIConfig(settings, path, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for section, keys := range settings.sections {
+		cmd := c
+		if section != "" {
+			for _, name := range strings.Split(section, ".") {
+				if cmd = cmd.lookupSubcommand(name); cmd == nil {
+					line := slices.MinFunc(firstValues(keys), func(a, b configValue) int { return a.line - b.line }).line
+					return nil, fmt.Errorf("%v:%v: unknown subcommand '%v'", path, line, section)
+				}
+			}
+		}
+		for key, values := range keys {
+			if cmd.lookupKey(key) == nil {
+				var names []string
+				for i := range cmd.Options {
+					names = append(names, cmd.Options[i].key())
+				}
+				err := fmt.Errorf("%v:%v: unknown setting '%v'", path, values[0].line, key)
+				if suggestions := lexopt.Suggest(key, names); len(suggestions) > 0 {
+					err = fmt.Errorf("%w; did you mean '%v'?", err, suggestions[0])
+				}
+				return nil, err
+			}
+		}
+	}
+	return settings, nil
+}
+
+// The first value of each key.
+func firstValues(keys map[string][]configValue) []configValue {
+	var values []configValue
+	for _, v := range keys {
+		values = append(values, v[0])
+	}
+	return values
+}
+
+func (c *Command) lookupKey(key string) *Option {
+	for i := range c.Options {
+		if c.Options[i].key() == key {
+			return &c.Options[i]
+		}
+	}
+	return nil
+}
+
+// The line that offset is on, counting from 1.
+func lineAt(data []byte, offset int64) int {
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func parseJSONConfig(settings *configSettings, path string, data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	fail := func(err error) error {
+		var syntaxError *json.SyntaxError
+		if errors.As(err, &syntaxError) {
+			return fmt.Errorf("%v:%v: %w", path, lineAt(data, min(syntaxError.Offset, int64(len(data)))), err)
+		}
+		return fmt.Errorf("%v:%v: %w", path, lineAt(data, decoder.InputOffset()), err)
+	}
+	// A scalar as a string.
+	scalar := func(token json.Token) (string, error) {
+		if s, ok := token.(string); ok {
+			return s, nil
+		} else if n, ok := token.(json.Number); ok {
+			return n.String(), nil
+		} else if b, ok := token.(bool); ok {
+			return strconv.FormatBool(b), nil
+		} else {
+			return "", fmt.Errorf("expected a string, number or boolean")
+		}
+	}
+
+	var object func(section string) error
+	object = func(section string) error {
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return fail(err)
+			}
+			key := token.(string)
+			line := lineAt(data, decoder.InputOffset())
+			token, err = decoder.Token()
+			if err != nil {
+				return fail(err)
+			}
+			if token == json.Delim('{') {
+				if err := object(strings.TrimPrefix(section+"."+key, ".")); err != nil {
+					return err
+				}
+			} else if token == json.Delim('[') {
+				for decoder.More() {
+					token, err := decoder.Token()
+					if err != nil {
+						return fail(err)
+					}
+					value, err := scalar(token)
+					if err != nil {
+						return fail(err)
+					}
+					settings.add(section, key, configValue{value, path, lineAt(data, decoder.InputOffset())})
+				}
+				if _, err := decoder.Token(); err != nil {
+					return fail(err)
+				}
+			} else {
+				value, err := scalar(token)
+				if err != nil {
+					return fail(err)
+				}
+				settings.add(section, key, configValue{value, path, line})
+			}
+		}
+		// The closing brace.
+		if _, err := decoder.Token(); err != nil {
+			return fail(err)
+		}
+		return nil
+	}
+
+	token, err := decoder.Token()
+	if err != nil {
+		return fail(err)
+	}
+	if token != json.Delim('{') {
+		return fail(fmt.Errorf("expected an object"))
+	}
+	if err := object(""); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fail(fmt.Errorf("unexpected data after the object"))
+	}
+	return nil
+}
+
+func parseINIConfig(settings *configSettings, path string, data []byte) error {
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+		if text[0] == '[' {
+			name, ok := strings.CutSuffix(stripINIComment(text), "]")
+			if !ok {
+				return fmt.Errorf("%v:%v: expected ] at the end of the section", path, line)
+			}
+			section = strings.TrimSpace(name[1:])
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return fmt.Errorf("%v:%v: expected key = value", path, line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			quoted, err := strconv.QuotedPrefix(value)
+			if err != nil {
+				return fmt.Errorf("%v:%v: unterminated or invalid quoted value", path, line)
+			}
+			if rest := stripINIComment(" " + value[len(quoted):]); rest != "" {
+				return fmt.Errorf("%v:%v: unexpected %v after the quoted value", path, line, rest)
+			}
+			value, _ = strconv.Unquote(quoted)
+		} else {
+			value = stripINIComment(value)
+		}
+		settings.add(section, key, configValue{value, path, line})
+	}
+	return scanner.Err()
+}
+
+// Remove a # or ; comment that follows whitespace, and the whitespace.
+func stripINIComment(s string) string {
+	for i := 1; i < len(s); i++ {
+		if (s[i] == '#' || s[i] == ';') && (s[i-1] == ' ' || s[i-1] == '\t') {
+			return strings.TrimSpace(s[:i])
+		}
+	}
+	return strings.TrimSpace(s)
+}
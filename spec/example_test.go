@@ -0,0 +1,48 @@
+package spec_test
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/jcbhmr/go-lexopt"
+	"github.com/jcbhmr/go-lexopt/spec"
+)
+
+func Example() {
+	cmd := &spec.Command{
+		Name: "hello",
+		Options: []spec.Option{
+			{Short: 'n', Long: "number", Kind: spec.Value, ValueName: "NUM", Type: spec.TypeOf[uint32](), Default: "1"},
+			{Long: "shout", Kind: spec.Flag},
+		},
+		Positionals: []spec.Positional{
+			{Name: "THING", Required: true},
+		},
+	}
+
+	parser := lexopt.ParserFromIter(slices.Values([]string{"hello", "-n3", "--shout", "Alan Turing"}))
+	result, err := cmd.Parse(parser)
+	if err != nil {
+		log.Fatal(err)
+	}
+	number, err := spec.Get[uint32](result, "number")
+	if err != nil {
+		log.Fatal(err)
+	}
+	thing, _ := result.String("THING")
+
+	message := fmt.Sprintf("Hello %s!", thing)
+	if result.Flag("shout") {
+		message = strings.ToUpper(message)
+	}
+	for i := uint32(0); i < number; i++ {
+		fmt.Println(message)
+	}
+
+	// Output:
+	// HELLO ALAN TURING!
+	// HELLO ALAN TURING!
+	// HELLO ALAN TURING!
+}
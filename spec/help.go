@@ -0,0 +1,259 @@
+package spec
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The widest the left column of help text gets before descriptions move to
+// their own line.
+const maxHelpColumn = 30
+
+// Write GNU-style help text for the command.
+//
+// The usage line is followed by the command's Help, then its positional
+// arguments, options and subcommands, each with their descriptions in an
+// aligned column. Descriptions are wrapped to width, which is found with
+// TerminalWidth() if it's 0. Defaults and environment variables are noted
+// after the description.
+//
+// parents are the names of the commands above a subcommand, to show in the
+// usage line, as in c.WriteHelp(os.Stdout, 0, "cargo").
+//
+//	Usage: hello [OPTIONS] THING
+//
+//	Print a greeting.
+//
+//	Arguments:
+//	  THING                 Who to greet
+//
+//	Options:
+//	  -n, --number=NUM      How many times to greet (default: 1)
+//	      --shout           Greet in uppercase
+func (c *Command) WriteHelp(w io.Writer, width int, parents ...string) error {
+	if width <= 0 {
+		width = TerminalWidth()
+	}
+	var b strings.Builder
+	b.WriteString("Usage: " + c.usage(parents) + "\n")
+	if c.Help != "" {
+		b.WriteString("\n")
+		for _, line := range wrap(c.Help, width) {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	var arguments, options, commands [][2]string
+	for i := range c.Positionals {
+		pos := &c.Positionals[i]
+		if pos.Help != "" {
+			arguments = append(arguments, [2]string{pos.placeholder(), pos.Help})
+		}
+	}
+	for i := range c.Options {
+		opt := &c.Options[i]
+		options = append(options, [2]string{opt.helpNames(), opt.helpText()})
+	}
+	for _, sub := range c.Subcommands {
+		commands = append(commands, [2]string{sub.Name, sub.Help})
+	}
+
+	column := 0
+	for _, rows := range [][][2]string{arguments, options, commands} {
+		for _, row := range rows {
+			if n := utf8.RuneCountInString(row[0]); n <= maxHelpColumn {
+				column = max(column, n)
+			}
+		}
+	}
+	// Two spaces of indentation, then at least two spaces of separation.
+	column += 4
+
+	writeSection(&b, "Arguments", arguments, column, width)
+	writeSection(&b, "Options", options, column, width)
+	writeSection(&b, "Commands", commands, column, width)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// The help text as a string, for a terminal as wide as width.
+func (c *Command) HelpText(width int, parents ...string) string {
+	var b strings.Builder
+	c.WriteHelp(&b, width, parents...)
+	return b.String()
+}
+
+// Guess how wide the terminal is.
+//
+// The COLUMNS environment variable wins if it's set, then the size of the
+// terminal on stdout. If neither is available it's 80.
+func TerminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if columns := ioctlWidth(); columns > 0 {
+		return columns
+	}
+	return 80
+}
+
+// The usage line, like "cargo install [OPTIONS] CRATE...".
+func (c *Command) usage(parents []string) string {
+	parts := append([]string{}, parents...)
+	parts = append(parts, c.Name)
+	if len(c.Options) > 0 {
+		parts = append(parts, "[OPTIONS]")
+	}
+	for i := range c.Positionals {
+		parts = append(parts, c.Positionals[i].usage())
+	}
+	if len(c.Subcommands) > 0 {
+		parts = append(parts, "[COMMAND]")
+	}
+	return strings.Join(parts, " ")
+}
+
+func (p *Positional) placeholder() string {
+	if p.Multiple {
+		return p.Name + "..."
+	}
+	return p.Name
+}
+
+func (p *Positional) usage() string {
+	if p.Required {
+		return p.placeholder()
+	}
+	return "[" + p.placeholder() + "]"
+}
+
+func (o *Option) valueName() string {
+	if o.ValueName != "" {
+		return o.ValueName
+	}
+	return "VALUE"
+}
+
+// The left column for an option, like "-n, --number=NUM",
+// "    --color[=WHEN]" or "    --[no-]color".
+func (o *Option) helpNames() string {
+	var s string
+	if o.Short != 0 {
+		s = fmt.Sprintf("-%c", o.Short)
+		if o.Long != "" {
+			s += ", "
+		}
+	} else {
+		// Keep long options lined up with the ones after a short option.
+		s = "    "
+	}
+	if o.Long != "" && o.Kind == Negatable {
+		s += "--[no-]" + o.Long
+	} else if o.Long != "" {
+		s += "--" + o.Long
+		if o.Kind == Value {
+			s += "=" + o.valueName()
+		} else if o.Kind == OptionalValue {
+			s += "[=" + o.valueName() + "]"
+		}
+	} else if o.Kind == Value {
+		s += " " + o.valueName()
+	} else if o.Kind == OptionalValue {
+		s += "[" + o.valueName() + "]"
+	}
+	return s
+}
+
+// The description of an option, with its default and environment variable.
+func (o *Option) helpText() string {
+	var notes []string
+	if len(o.Values) > 0 {
+		notes = append(notes, "possible values: "+strings.Join(o.Values, ", "))
+	}
+	if o.Default != "" {
+		notes = append(notes, "default: "+o.Default)
+	} else if o.HasDefault {
+		notes = append(notes, `default: ""`)
+	}
+	if o.Env != "" {
+		notes = append(notes, "env: "+o.Env)
+	}
+	if len(notes) == 0 {
+		return o.Help
+	}
+	note := "(" + strings.Join(notes, ", ") + ")"
+	if o.Help == "" {
+		return note
+	}
+	return o.Help + " " + note
+}
+
+// The description of a positional argument, with its possible values.
+func (p *Positional) helpText() string {
+	if len(p.Values) == 0 {
+		return p.Help
+	}
+	note := "(possible values: " + strings.Join(p.Values, ", ") + ")"
+	if p.Help == "" {
+		return note
+	}
+	return p.Help + " " + note
+}
+
+func writeSection(b *strings.Builder, title string, rows [][2]string, column int, width int) {
+	if len(rows) == 0 {
+		return
+	}
+	b.WriteString("\n" + title + ":\n")
+	for _, row := range rows {
+		left := "  " + row[0]
+		lines := wrap(row[1], max(width-column, 20))
+		n := utf8.RuneCountInString(left)
+		if len(lines) == 0 {
+			b.WriteString(left + "\n")
+			continue
+		}
+		if n+2 > column {
+			// Too long to share a line with the description.
+			b.WriteString(left + "\n")
+			b.WriteString(strings.Repeat(" ", column))
+		} else {
+			b.WriteString(left + strings.Repeat(" ", column-n))
+		}
+		b.WriteString(lines[0] + "\n")
+		for _, line := range lines[1:] {
+			b.WriteString(strings.Repeat(" ", column) + line + "\n")
+		}
+	}
+}
+
+// Split text into lines of at most width characters, breaking at spaces.
+// Words longer than width get a line of their own.
+func wrap(text string, width int) []string {
+	var lines []string
+	var line strings.Builder
+	lineLen := 0
+	for _, word := range strings.Fields(text) {
+		n := utf8.RuneCountInString(word)
+		if lineLen > 0 && lineLen+1+n > width {
+			lines = append(lines, line.String())
+			line.Reset()
+			lineLen = 0
+		}
+		if lineLen > 0 {
+			line.WriteString(" ")
+			lineLen++
+		}
+		line.WriteString(word)
+		lineLen += n
+	}
+	if lineLen > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
@@ -0,0 +1,60 @@
+package spec
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// Compare got to testdata/name, or write it there with -update.
+func golden(t *testing.T, name string, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.Nil(t, os.MkdirAll(filepath.Dir(path), 0o777))
+		require.Nil(t, os.WriteFile(path, []byte(got), 0o666))
+	}
+	want, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Equal(t, string(want), got)
+}
+
+var hello = &Command{
+	Name: "hello",
+	Help: "Print a greeting to standard output, as many times as you like.",
+	Options: []Option{
+		{Short: 'n', Long: "number", Kind: Value, ValueName: "NUM", Default: "1", Env: "HELLO_NUMBER", Help: "How many times to print the greeting"},
+		{Long: "shout", Kind: Flag, Help: "Print the greeting in uppercase"},
+		{Long: "color", Kind: Negatable, Default: "true", Help: "Color the greeting"},
+		{Long: "a-rather-long-option-name", Kind: Value, ValueName: "SOMETHING", Help: "An option whose name doesn't fit in the column"},
+		{Short: 'h', Long: "help", Kind: Flag, Help: "Print help"},
+	},
+	Positionals: []Positional{
+		{Name: "THING", Required: true, Help: "Who or what to greet"},
+		{Name: "MORE", Multiple: true, Help: "Other things to greet"},
+	},
+}
+
+func TestHelp(t *testing.T) {
+	golden(t, "help/hello-80.txt", hello.HelpText(80))
+	golden(t, "help/hello-40.txt", hello.HelpText(40))
+	golden(t, "help/cargo.txt", cargo.HelpText(80))
+	golden(t, "help/cargo-install.txt", install.HelpText(80, "cargo"))
+}
+
+func TestTerminalWidth(t *testing.T) {
+	t.Setenv("COLUMNS", "123")
+	require.Equal(t, 123, TerminalWidth())
+	t.Setenv("COLUMNS", "")
+	require.Greater(t, TerminalWidth(), 0)
+}
+
+func TestWrap(t *testing.T) {
+	require.Equal(t, []string{"aaa bbb", "cccccccccc", "d"}, wrap("aaa bbb  cccccccccc d", 7))
+	require.Nil(t, wrap("", 10))
+}
@@ -0,0 +1,209 @@
+package spec
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Write a man page for the command, in roff with the man(7) macros.
+//
+// The page has NAME, SYNOPSIS, DESCRIPTION, OPTIONS, COMMANDS,
+// ENVIRONMENT, EXIT STATUS and SEE ALSO sections, leaving out the ones
+// that would be empty. ENVIRONMENT lists the Env of each option.
+//
+// parents are the names of the commands above a subcommand, as for
+// WriteHelp(). A subcommand's page is named after all of them, like
+// cargo-install(1), and subcommands are referred to by their own pages
+// instead of being described in full. Use WriteManPages() to write the
+// page of every subcommand.
+func (c *Command) WriteMan(w io.Writer, section int, parents ...string) error {
+	path := append(parents[:len(parents):len(parents)], c.Name)
+	title := strings.Join(path, "-")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TH %v %v\n", roffQuote(strings.ToUpper(title)), section)
+	b.WriteString(".SH NAME\n")
+	if c.Help != "" {
+		b.WriteString(roffEscape(title) + ` \- ` + roffEscape(c.Help) + "\n")
+	} else {
+		b.WriteString(roffEscape(title) + "\n")
+	}
+
+	b.WriteString(".SH SYNOPSIS\n")
+	b.WriteString(c.manSynopsis(path) + "\n")
+
+	if paragraphs := c.paragraphs(); len(paragraphs) > 0 {
+		b.WriteString(".SH DESCRIPTION\n")
+		for i, paragraph := range paragraphs {
+			if i > 0 {
+				b.WriteString(".PP\n")
+			}
+			b.WriteString(roffText(paragraph) + "\n")
+		}
+	}
+
+	if len(c.Positionals) > 0 || len(c.Options) > 0 {
+		b.WriteString(".SH OPTIONS\n")
+		for i := range c.Options {
+			opt := &c.Options[i]
+			b.WriteString(".TP\n")
+			b.WriteString(opt.manNames() + "\n")
+			if text := opt.helpText(); text != "" {
+				b.WriteString(roffText(text) + "\n")
+			}
+		}
+		for i := range c.Positionals {
+			pos := &c.Positionals[i]
+			b.WriteString(".TP\n")
+			b.WriteString(`\fI` + roffEscape(pos.placeholder()) + `\fR` + "\n")
+			if text := pos.helpText(); text != "" {
+				b.WriteString(roffText(text) + "\n")
+			}
+		}
+	}
+
+	if len(c.Subcommands) > 0 {
+		b.WriteString(".SH COMMANDS\n")
+		for _, sub := range c.Subcommands {
+			b.WriteString(".TP\n")
+			fmt.Fprintf(&b, `\fB%v\fR(%v)`+"\n", roffEscape(title+"-"+sub.Name), section)
+			if sub.Help != "" {
+				b.WriteString(roffText(sub.Help) + "\n")
+			}
+		}
+	}
+
+	var env []*Option
+	for i := range c.Options {
+		if c.Options[i].Env != "" {
+			env = append(env, &c.Options[i])
+		}
+	}
+	if len(env) > 0 {
+		b.WriteString(".SH ENVIRONMENT\n")
+		for _, opt := range env {
+			b.WriteString(".TP\n")
+			b.WriteString(`\fB` + roffEscape(opt.Env) + `\fR` + "\n")
+			fmt.Fprintf(&b, `Used as the value of \fB%v\fR if it isn't given.`+"\n", roffEscape(opt.displayName()))
+		}
+	}
+
+	b.WriteString(".SH EXIT STATUS\n")
+	for _, status := range c.exitStatus() {
+		b.WriteString(".TP\n")
+		fmt.Fprintf(&b, `\fB%v\fR`+"\n", status.Code)
+		if status.Help != "" {
+			b.WriteString(roffText(status.Help) + "\n")
+		}
+	}
+
+	var seeAlso []string
+	if len(parents) > 0 {
+		seeAlso = append(seeAlso, fmt.Sprintf(`\fB%v\fR(%v)`, roffEscape(strings.Join(parents, "-")), section))
+	}
+	for _, sub := range c.Subcommands {
+		seeAlso = append(seeAlso, fmt.Sprintf(`\fB%v\fR(%v)`, roffEscape(title+"-"+sub.Name), section))
+	}
+	if len(seeAlso) > 0 {
+		b.WriteString(".SH SEE ALSO\n")
+		b.WriteString(strings.Join(seeAlso, ",\n") + "\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Write the man pages of the command and all of its subcommands into dir.
+//
+// The files are named like cargo.1 and cargo-install.1, after the section.
+func (c *Command) WriteManPages(dir string, section int) error {
+	for _, cp := range c.walk() {
+		var b strings.Builder
+		if err := cp.WriteMan(&b, section, cp.path[:len(cp.path)-1]...); err != nil {
+			return err
+		}
+		name := fmt.Sprintf("%v.%v", strings.Join(cp.path, "-"), section)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(b.String()), 0o666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The man page as a string.
+func (c *Command) ManText(section int, parents ...string) string {
+	var b strings.Builder
+	c.WriteMan(&b, section, parents...)
+	return b.String()
+}
+
+// The synopsis, like \fBcargo install\fR [\fIOPTIONS\fR] \fICRATE\fR...
+func (c *Command) manSynopsis(path []string) string {
+	parts := []string{`\fB` + roffEscape(strings.Join(path, " ")) + `\fR`}
+	if len(c.Options) > 0 {
+		parts = append(parts, `[\fIOPTIONS\fR]`)
+	}
+	for i := range c.Positionals {
+		pos := &c.Positionals[i]
+		s := `\fI` + roffEscape(pos.Name) + `\fR`
+		if pos.Multiple {
+			s += "..."
+		}
+		if !pos.Required {
+			s = "[" + s + "]"
+		}
+		parts = append(parts, s)
+	}
+	if len(c.Subcommands) > 0 {
+		parts = append(parts, `[\fICOMMAND\fR]`)
+	}
+	return strings.Join(parts, " ")
+}
+
+// The names of an option for a man page, like
+// \fB\-n\fR, \fB\-\-number\fR=\fINUM\fR.
+func (o *Option) manNames() string {
+	var names []string
+	for _, name := range o.spellings() {
+		names = append(names, `\fB`+roffEscape(name)+`\fR`)
+	}
+	s := strings.Join(names, ", ")
+	if o.Kind == Value {
+		if o.Long != "" {
+			s += "="
+		} else {
+			s += " "
+		}
+		s += `\fI` + roffEscape(o.valueName()) + `\fR`
+	} else if o.Kind == OptionalValue {
+		if o.Long != "" {
+			s += "[=" + `\fI` + roffEscape(o.valueName()) + `\fR]`
+		} else {
+			s += `[\fI` + roffEscape(o.valueName()) + `\fR]`
+		}
+	}
+	return s
+}
+
+// Escape text for roff.
+func roffEscape(s string) string {
+	return strings.NewReplacer(`\`, `\e`, `-`, `\-`).Replace(s)
+}
+
+// Escape running text for roff, on a single line. A line that starts with
+// a period or an apostrophe would be taken for a request.
+func roffText(s string) string {
+	s = roffEscape(strings.Join(strings.Fields(s), " "))
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+// Quote an argument to a roff macro.
+func roffQuote(s string) string {
+	return `"` + strings.ReplaceAll(roffEscape(s), `"`, `\(dq`) + `"`
+}
@@ -0,0 +1,42 @@
+package spec
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMan(t *testing.T) {
+	golden(t, "man/hello.1", hello.ManText(1))
+	golden(t, "man/vcs.1", vcs.ManText(1))
+
+	dir := t.TempDir()
+	require.Nil(t, vcs.WriteManPages(dir, 1))
+	for _, name := range []string{"vcs", "vcs-commit", "vcs-remote", "vcs-remote-add", "vcs-remote-remove"} {
+		page, err := os.ReadFile(filepath.Join(dir, name+".1"))
+		require.Nil(t, err)
+		golden(t, "man/"+name+".1", string(page))
+	}
+}
+
+// Check that man accepts the pages, if it's installed.
+func TestManRenders(t *testing.T) {
+	man, err := exec.LookPath("man")
+	if err != nil {
+		t.Skip("man not found")
+	}
+	for _, name := range []string{"hello.1", "vcs-commit.1"} {
+		cmd := exec.Command(man, "-l", filepath.Join("testdata", "man", name))
+		cmd.Env = append(os.Environ(), "MANPAGER=cat", "MANWIDTH=80")
+		out, err := cmd.CombinedOutput()
+		require.Nil(t, err, string(out))
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	golden(t, "markdown/hello.md", hello.MarkdownText())
+	golden(t, "markdown/vcs.md", vcs.MarkdownText())
+}
@@ -0,0 +1,136 @@
+package spec
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Write a Markdown reference for the command and all of its subcommands.
+//
+// Each command gets a heading with its full name, like "cargo install",
+// followed by its usage, description, arguments, options, environment
+// variables, exit statuses and subcommands. Subcommands link to their own
+// sections, which come after their parent's, one heading level down.
+func (c *Command) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	for i, cp := range c.walk() {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		cp.writeMarkdown(&b)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// The Markdown reference as a string.
+func (c *Command) MarkdownText() string {
+	var b strings.Builder
+	c.WriteMarkdown(&b)
+	return b.String()
+}
+
+func (cp commandPath) writeMarkdown(b *strings.Builder) {
+	heading := strings.Repeat("#", min(len(cp.path), 5))
+	name := strings.Join(cp.path, " ")
+	fmt.Fprintf(b, "%v %v\n\n", heading, name)
+	if cp.Help != "" {
+		b.WriteString(markdownEscape(cp.Help) + "\n\n")
+	}
+	b.WriteString("```\n" + cp.usage(cp.path[:len(cp.path)-1]) + "\n```\n")
+	for _, paragraph := range cp.paragraphs() {
+		b.WriteString("\n" + markdownEscape(paragraph) + "\n")
+	}
+
+	section := func(title string) {
+		fmt.Fprintf(b, "\n%v# %v\n\n", heading, title)
+	}
+	if len(cp.Positionals) > 0 {
+		section("Arguments")
+		for i := range cp.Positionals {
+			pos := &cp.Positionals[i]
+			markdownItem(b, "`"+pos.placeholder()+"`", markdownEscape(pos.helpText()))
+		}
+	}
+	if len(cp.Options) > 0 {
+		section("Options")
+		for i := range cp.Options {
+			opt := &cp.Options[i]
+			markdownItem(b, opt.markdownNames(), markdownEscape(opt.helpText()))
+		}
+	}
+	var env []*Option
+	for i := range cp.Options {
+		if cp.Options[i].Env != "" {
+			env = append(env, &cp.Options[i])
+		}
+	}
+	if len(env) > 0 {
+		section("Environment")
+		for _, opt := range env {
+			markdownItem(b, "`"+opt.Env+"`", "Used as the value of `"+opt.displayName()+"` if it isn't given.")
+		}
+	}
+	section("Exit status")
+	for _, status := range cp.exitStatus() {
+		markdownItem(b, fmt.Sprintf("`%v`", status.Code), markdownEscape(status.Help))
+	}
+	if len(cp.Subcommands) > 0 {
+		section("Commands")
+		for _, sub := range cp.Subcommands {
+			child := cp.child(sub)
+			link := fmt.Sprintf("[`%v`](#%v)", sub.Name, markdownAnchor(strings.Join(child.path, " ")))
+			markdownItem(b, link, markdownEscape(sub.Help))
+		}
+	}
+}
+
+// The names of an option, like `-n`, `--number=NUM`.
+func (o *Option) markdownNames() string {
+	names := o.spellings()
+	if o.Kind == Value {
+		if o.Long != "" {
+			names[len(names)-1] += "=" + o.valueName()
+		} else {
+			names[len(names)-1] += " " + o.valueName()
+		}
+	} else if o.Kind == OptionalValue {
+		if o.Long != "" {
+			names[len(names)-1] += "[=" + o.valueName() + "]"
+		} else {
+			names[len(names)-1] += "[" + o.valueName() + "]"
+		}
+	}
+	return "`" + strings.Join(names, "`, `") + "`"
+}
+
+func markdownItem(b *strings.Builder, name string, text string) {
+	if text == "" {
+		fmt.Fprintf(b, "- %v\n", name)
+	} else {
+		fmt.Fprintf(b, "- %v: %v\n", name, strings.Join(strings.Fields(text), " "))
+	}
+}
+
+// Escape the characters that Markdown would take for formatting.
+func markdownEscape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`,
+	).Replace(s)
+}
+
+// The anchor GitHub gives a heading: lowercase, with spaces turned into
+// hyphens and most punctuation dropped.
+func markdownAnchor(heading string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' {
+			return '-'
+		} else if r >= 'A' && r <= 'Z' {
+			return r - 'A' + 'a'
+		} else if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return -1
+	}, heading)
+}
@@ -0,0 +1,304 @@
+package spec
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jcbhmr/go-lexopt"
+)
+
+// What Command.Parse() found on the command line.
+//
+// Options and positional arguments are looked up by their key: Option.Name
+// (or the long or short name) and Positional.Name. Looking up a key the
+// command doesn't declare panics, since that's a programming error.
+type Result struct {
+	// The command this is the result for.
+	Command *Command
+	// The result for the subcommand, if one was given.
+	Subcommand *Result
+	values     map[string][]occurrence
+	lookupEnv  func(key string) (string, bool)
+	// The settings from configuration files, and the section for this
+	// command.
+	config  *configSettings
+	section string
+}
+
+// One appearance of an option or positional argument.
+type occurrence struct {
+	// The option as it was written, like -n or --number, or the
+	// environment variable it came from, like $HELLO_NUMBER. Empty for
+	// positional arguments.
+	option     string
+	optionSpan lexopt.Span
+	value      string
+	// The span of the value, or of the option for flags.
+	span lexopt.Span
+	// Where it came from, if not the command line. Only then are the
+	// spans meaningful.
+	from Source
+}
+
+// Where the occurrence came from.
+func (o occurrence) source() Source {
+	if o.from.Kind == SourceCommandLine {
+		return Source{Kind: SourceCommandLine, Index: o.optionSpan.Index}
+	}
+	return o.from
+}
+
+// A Result for c, which shares the environment and configuration of the
+// parent command's Result, if there is one.
+func newResult(c *Command, parent *Result) *Result {
+	r := &Result{
+		Command:   c,
+		values:    map[string][]occurrence{},
+		lookupEnv: c.LookupEnv,
+	}
+	if parent != nil {
+		if r.lookupEnv == nil {
+			r.lookupEnv = parent.lookupEnv
+		}
+		r.config = parent.config
+		r.section = strings.TrimPrefix(parent.section+"."+c.Name, ".")
+	}
+	if r.lookupEnv == nil {
+		r.lookupEnv = os.LookupEnv
+	}
+	return r
+}
+
+func (r *Result) add(key string, o occurrence) {
+	r.values[key] = append(r.values[key], o)
+}
+
+// Find the option or positional argument declared under key.
+func (r *Result) lookup(key string) (*Option, *Positional) {
+	for i := range r.Command.Options {
+		if r.Command.Options[i].key() == key {
+			return &r.Command.Options[i], nil
+		}
+	}
+	for i := range r.Command.Positionals {
+		if r.Command.Positionals[i].Name == key {
+			return nil, &r.Command.Positionals[i]
+		}
+	}
+	panic(fmt.Sprintf("spec: command %v has no option or argument %v", r.Command.Name, key))
+}
+
+// Report whether an option or positional argument was given on the
+// command line.
+func (r *Result) Has(key string) bool {
+	r.lookup(key)
+	return len(r.values[key]) > 0
+}
+
+// Report whether a flag was given.
+//
+// For a Negatable option this is its last setting instead, so --color
+// --no-color is false. If it wasn't given it's the option's Default, which
+// should be "true" or "false".
+func (r *Result) Flag(key string) bool {
+	option, _ := r.lookup(key)
+	if option == nil || option.Kind != Negatable {
+		return r.Has(key)
+	}
+	value, _ := r.String(key)
+	on, _ := strconv.ParseBool(value)
+	return on
+}
+
+// The number of times an option or positional argument was given.
+func (r *Result) Count(key string) int {
+	r.lookup(key)
+	return len(r.values[key])
+}
+
+// The level of a Count option.
+//
+// It starts at the option's Default, or 0, and every occurrence of the
+// option or of an option that Decrements it moves it up or down one, in
+// the order they were given and within their Max. An explicit level, as
+// in --verbose=3, replaces whatever came before it. So with -q
+// decrementing -v, -vv -q is 1 and -q is -1.
+func (r *Result) Level(key string) int {
+	option, _ := r.lookup(key)
+	if option == nil || option.Kind != Count {
+		panic(fmt.Sprintf("spec: option %v of command %v isn't a Count option", key, r.Command.Name))
+	}
+	level, _ := strconv.Atoi(option.Default)
+	for _, s := range r.levelSteps(option) {
+		if s.option != option {
+			level--
+			if s.option.Max > 0 {
+				level = max(level, -s.option.Max)
+			}
+		} else if s.value != "" {
+			level, _ = strconv.Atoi(s.value)
+		} else {
+			level++
+			if option.Max > 0 {
+				level = min(level, option.Max)
+			}
+		}
+	}
+	return level
+}
+
+// An occurrence of a Count option or of an option that Decrements it.
+type levelStep struct {
+	option *Option
+	occurrence
+}
+
+// The occurrences that move the level of a Count option, in order.
+//
+// Those from the environment and configuration files come first, with the
+// option's own level before the steps of the options that Decrement it.
+func (r *Result) levelSteps(option *Option) []levelStep {
+	var steps []levelStep
+	for _, occ := range r.values[option.key()] {
+		steps = append(steps, levelStep{option, occ})
+	}
+	for i := range r.Command.Options {
+		o := &r.Command.Options[i]
+		if o.Kind == Count && o.Decrements == option.key() {
+			for _, occ := range r.values[o.key()] {
+				steps = append(steps, levelStep{o, occ})
+			}
+		}
+	}
+	slices.SortStableFunc(steps, func(a, b levelStep) int {
+		return cmp.Or(a.optionSpan.Index-b.optionSpan.Index, a.optionSpan.Start-b.optionSpan.Start)
+	})
+	return steps
+}
+
+// The value of an option or positional argument.
+//
+// If it was given more than once the last value wins. If it wasn't given
+// the option's Default is returned, and ok is false if there is none.
+func (r *Result) String(key string) (value string, ok bool) {
+	option, _ := r.lookup(key)
+	if values := r.values[key]; len(values) > 0 {
+		return values[len(values)-1].value, true
+	}
+	if option != nil && option.hasDefault() {
+		return option.Default, true
+	}
+	return "", false
+}
+
+// All values of an option or positional argument, in order.
+//
+// If it wasn't given this is the option's Default, if any.
+func (r *Result) Strings(key string) []string {
+	option, _ := r.lookup(key)
+	values := r.values[key]
+	if len(values) == 0 {
+		if option != nil && option.hasDefault() {
+			return []string{option.Default}
+		}
+		return nil
+	}
+	strings := make([]string, len(values))
+	for i, o := range values {
+		strings[i] = o.value
+	}
+	return strings
+}
+
+// The value of an option or positional argument, parsed into a T.
+//
+// This is result.String() followed by lexopt.ParseValue[T](). If there's no
+// value the zero T is returned.
+//
+// # Errors
+//
+// An ErrorParsingFailed that points at the value on the command line. If
+// the option's Type is TypeOf[T]() Parse() has already reported any value
+// that would fail.
+func Get[T any](r *Result, key string) (T, lexopt.Error) {
+	option, _ := r.lookup(key)
+	var zero T
+	if values := r.values[key]; len(values) > 0 {
+		return parseOccurrence[T](values[len(values)-1])
+	}
+	if option != nil && option.hasDefault() {
+		return parseDefault[T](option)
+	}
+	return zero, nil
+}
+
+// All values of an option or positional argument, parsed into Ts.
+func GetAll[T any](r *Result, key string) ([]T, lexopt.Error) {
+	option, _ := r.lookup(key)
+	values := r.values[key]
+	if len(values) == 0 {
+		if option != nil && option.hasDefault() {
+			v, err := parseDefault[T](option)
+			if err != nil {
+				return nil, err
+			}
+			return []T{v}, nil
+		}
+		return nil, nil
+	}
+	result := make([]T, len(values))
+	for i, o := range values {
+		v, err := parseOccurrence[T](o)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = v
+	}
+	return result, nil
+}
+
+func parseOccurrence[T any](o occurrence) (T, lexopt.Error) {
+	v, err := lexopt.ParseValue[T](o.value)
+	if err != nil {
+		span := o.span
+		e := &lexopt.ErrorParsingFailed{
+			Index:  o.optionSpan.Index,
+			Value:  o.value,
+			Error2: err,
+			Span:   &span,
+		}
+		if o.from.Kind != SourceCommandLine {
+			e.Span = nil
+		}
+		if o.option != "" {
+			e.Option = &o.option
+		}
+		return v, e
+	}
+	return v, nil
+}
+
+func parseDefault[T any](option *Option) (T, lexopt.Error) {
+	v, err := lexopt.ParseValue[T](option.Default)
+	if err != nil {
+		name := option.displayName()
+		return v, &lexopt.ErrorParsingFailed{
+			Option: &name,
+			Value:  option.Default,
+			Error2: err,
+		}
+	}
+	return v, nil
+}
+
+// The option's preferred spelling, like --number or -n.
+func (o *Option) displayName() string {
+	if o.Long != "" {
+		return "--" + o.Long
+	}
+	return fmt.Sprintf("-%c", o.Short)
+}
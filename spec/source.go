@@ -0,0 +1,189 @@
+package spec
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Where the value of an option came from.
+type SourceKind uint8
+
+const (
+	// The command line.
+	SourceCommandLine SourceKind = iota
+	// An environment variable, from Option.Env.
+	SourceEnv
+	// A configuration file.
+	SourceConfig
+	// The option's Default, or for a flag, not being given at all.
+	SourceDefault
+)
+
+// Where the value of an option came from, from result.Source().
+type Source struct {
+	Kind SourceKind
+	// For SourceCommandLine, the index of the argument the option was in,
+	// not counting the binary name.
+	Index int
+	// For SourceEnv, the name of the variable.
+	Env string
+	// For SourceConfig, the file and the line in it, counting from 1.
+	Path string
+	Line int
+}
+
+// Describe the source, like argv[3], $MYAPP_TOKEN, app.ini:12 or default.
+// The argv index counts the binary name, as os.Args does.
+func (s Source) String() string {
+	switch s.Kind {
+	case SourceCommandLine:
+		return fmt.Sprintf("argv[%v]", s.Index+1)
+	case SourceEnv:
+		return "$" + s.Env
+	case SourceConfig:
+		return fmt.Sprintf("%v:%v", s.Path, s.Line)
+	default:
+		return "default"
+	}
+}
+
+// Where the effective value of an option came from.
+//
+// That's the last place it was set, as for result.String(). Flags and
+// Count options always have a value, which is SourceDefault if they weren't
+// given. Other options without a value or a Default aren't anywhere, and
+// ok is false.
+//
+// For a Count option that's only where the option itself was last given.
+// Its level can also depend on where it was given before that and on
+// options that Decrement it, as in $MYAPP_VERBOSE=2 with -q on the command
+// line. result.WriteConfig() lists all of those sources.
+func (r *Result) Source(key string) (source Source, ok bool) {
+	option, _ := r.lookup(key)
+	if values := r.values[key]; len(values) > 0 {
+		return values[len(values)-1].source(), true
+	}
+	if option != nil && (option.hasDefault() || option.Kind == Flag || option.Kind == Negatable || option.Kind == Count) {
+		return Source{Kind: SourceDefault}, true
+	}
+	return Source{}, false
+}
+
+// Write the effective settings, with where each came from, for something
+// like a --dump-config option.
+//
+// Every option that has a value is written as key = value, followed by its
+// source as a comment. Options given several times get a line for each
+// value, and a Count option's comment lists every source of its level.
+// The options of subcommands follow in a section named after the
+// subcommand, like [install] or [remote.add]. Values that need it are
+// quoted as Go strings.
+//
+// The output reads back as a configuration file with the same settings.
+// A Count option's level takes in the options that Decrement it, so those
+// aren't written themselves.
+//
+//	jobs    = 8     # $CARGO_JOBS
+//	color   = never # argv[2]
+//	verbose = 2     # default
+//
+//	[install]
+//	root = /opt/cargo # cargo.ini:3
+func (r *Result) WriteConfig(w io.Writer) error {
+	var b strings.Builder
+	var path []string
+	for result := r; result != nil; result = result.Subcommand {
+		if result != r {
+			path = append(path, result.Command.Name)
+			b.WriteString("\n[" + strings.Join(path, ".") + "]\n")
+		}
+		var rows [][3]string
+		for i := range result.Command.Options {
+			option := &result.Command.Options[i]
+			for _, setting := range result.settings(option) {
+				rows = append(rows, [3]string{option.key(), setting[0], setting[1]})
+			}
+		}
+		keyWidth, valueWidth := 0, 0
+		for _, row := range rows {
+			keyWidth = max(keyWidth, utf8.RuneCountInString(row[0]))
+			valueWidth = max(valueWidth, utf8.RuneCountInString(row[1]))
+		}
+		for _, row := range rows {
+			fmt.Fprintf(&b, "%-*v = %-*v # %v\n", keyWidth, row[0], valueWidth, row[1], row[2])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// The settings as a string, as for result.WriteConfig().
+func (r *Result) ConfigText() string {
+	var b strings.Builder
+	r.WriteConfig(&b)
+	return b.String()
+}
+
+// The values of an option for WriteConfig(), quoted if need be, with their
+// sources.
+func (r *Result) settings(option *Option) [][2]string {
+	key := option.key()
+	source, ok := r.Source(key)
+	if !ok || option.Decrements != "" {
+		return nil
+	}
+	if option.Kind == Flag || option.Kind == Negatable {
+		return [][2]string{{strconv.FormatBool(r.Flag(key)), source.String()}}
+	} else if option.Kind == Count {
+		return [][2]string{{strconv.Itoa(r.Level(key)), r.levelSources(option)}}
+	}
+	values := r.values[key]
+	if len(values) == 0 {
+		return [][2]string{{quoteConfigValue(option.Default), source.String()}}
+	}
+	settings := make([][2]string, len(values))
+	for i, o := range values {
+		settings[i] = [2]string{quoteConfigValue(o.value), o.source().String()}
+	}
+	return settings
+}
+
+// Where the level of a Count option came from, like "$MYAPP_VERBOSE,
+// argv[2]": every occurrence since the last explicit level, or since the
+// Default if there isn't one.
+func (r *Result) levelSources(option *Option) string {
+	steps := r.levelSteps(option)
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i].option == option && steps[i].value != "" {
+			steps = steps[i:]
+			break
+		}
+	}
+	var sources []string
+	if len(steps) == 0 || steps[0].option != option || steps[0].value == "" {
+		if option.hasDefault() || len(steps) == 0 {
+			sources = append(sources, Source{Kind: SourceDefault}.String())
+		}
+	}
+	for _, s := range steps {
+		// -vv is one source.
+		if source := s.source().String(); len(sources) == 0 || sources[len(sources)-1] != source {
+			sources = append(sources, source)
+		}
+	}
+	return strings.Join(sources, ", ")
+}
+
+// Quote a value with strconv.Quote() if it would otherwise be read back
+// differently.
+func quoteConfigValue(value string) string {
+	if value == "" || strings.TrimSpace(value) != value || strings.ContainsAny(value, "\"#;") ||
+		strings.ContainsFunc(value, func(r rune) bool { return !unicode.IsPrint(r) }) {
+		return strconv.Quote(value)
+	}
+	return value
+}
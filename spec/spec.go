@@ -0,0 +1,641 @@
+/*
+Declarative option specifications on top of lexopt.
+
+A Command lists its options, positional arguments and subcommands.
+Command.Parse() runs the usual parser.Next() loop for it, taking values
+with parser.Value(), and collects what it finds in a Result. The
+tokenisation is exactly lexopt's: -abc, -ovalue, --option=value and --
+all behave the same as in a hand-written loop, and errors are the usual
+lexopt.Error values.
+
+	cmd := &spec.Command{
+	    Name: "hello",
+	    Options: []spec.Option{
+	        {Short: 'n', Long: "number", Kind: spec.Value, ValueName: "NUM", Type: spec.TypeOf[uint32](), Default: "1"},
+	        {Long: "shout", Kind: spec.Flag},
+	    },
+	    Positionals: []spec.Positional{
+	        {Name: "THING", Required: true},
+	    },
+	}
+	result, err := cmd.Parse(lexopt.ParserFromEnv())
+	if err != nil {
+	    log.Fatal(err)
+	}
+	number, err := spec.Get[uint32](result, "number")
+*/
+package spec
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jcbhmr/go-lexopt"
+)
+
+// What an option expects after it.
+type Kind uint8
+
+const (
+	// The option never takes a value, as in --verbose.
+	Flag Kind = iota
+	// The option takes a value, as in --output FILE or --output=FILE.
+	Value
+	// The option may take a value, but only an attached one, as in
+	// --color=WHEN or -OLEVEL. --color WHEN is --color followed by a
+	// positional argument. Without a value it's Option.Implicit.
+	OptionalValue
+	// A flag that can be turned off again, as in --color and --no-color.
+	// It can also be set explicitly with --color=true or --color=false,
+	// or any other value strconv.ParseBool() accepts, but only attached
+	// with =. The --no- form needs a Long name. Use result.Flag() to get
+	// the last setting.
+	Negatable
+	// A flag that counts how often it's given, as in -vvv or -v -v. The
+	// level can also be set explicitly with --verbose=3, attached with =.
+	// Another Count option can count it back down, as -q does for -v; see
+	// Option.Decrements. Use result.Level() to get the level.
+	Count
+)
+
+// What sort of value an option or positional argument takes, for shell
+// completion.
+type Hint uint8
+
+const (
+	// Nothing in particular.
+	HintNone Hint = iota
+	// A path to a file.
+	HintFile
+	// A path to a directory.
+	HintDir
+)
+
+// An option, like -n or --number.
+type Option struct {
+	// The key the option is stored under in a Result. If empty it's Long,
+	// or Short if there's no Long.
+	Name string
+	// The short name, without the dash. 0 if there is none.
+	Short rune
+	// The long name, without the dashes. Empty if there is none.
+	Long string
+	Kind Kind
+	// A placeholder for the value in help text, like NUM.
+	ValueName string
+	// The values the option accepts. If it's set any other value is an
+	// error. Values shouldn't contain whitespace, since they're also used
+	// for shell completion.
+	Values []string
+	// Check that a value is of the option's type, usually TypeOf[T]() for
+	// the T that spec.Get[T] will be asked for. Parse() reports values it
+	// rejects, so Get[T] can't fail afterwards. Nil accepts anything.
+	Type func(value string) error
+	// What sort of value the option takes, for shell completion.
+	Hint Hint
+	// Find possible values that start with prefix, for dynamic completion
+	// with Command.WriteCompletions(), which the completion scripts call.
+	// This is for values that depend on the state of the world, like
+	// branch names. It takes precedence over Values and Hint.
+	Complete func(prefix string) []lexopt.Candidate
+	// The value to use if the option isn't given. For a Count option it's
+	// the starting level.
+	Default string
+	// Whether Default applies even though it's empty. An empty Default
+	// otherwise means there is none.
+	HasDefault bool
+	// The value of an OptionalValue option that's given without one, like
+	// always for --color.
+	Implicit string
+	// The highest level a Count option goes to, or for an option that
+	// Decrements another, the furthest it counts down. Repeating it past
+	// that has no effect, but a higher explicit level is an error. 0 means
+	// no limit.
+	Max int
+	// The key of a Count option that this Count option counts down, like
+	// "verbose" for --quiet. It doesn't take an explicit level on the
+	// command line. A level from its Env or a configuration file counts
+	// down that many times, as if it was repeated. The option it counts
+	// down can then be given a negative level, as far down as this one's
+	// Max.
+	Decrements string
+	// An environment variable that sets the option if it isn't on the
+	// command line. It's read with Command.LookupEnv, and an empty value
+	// counts as unset. For a Flag it's parsed with strconv.ParseBool(), and
+	// for a Count option it's the level. It's mentioned in help text.
+	Env string
+	// A description of the option.
+	Help string
+}
+
+// A positional argument.
+type Positional struct {
+	// The key the argument is stored under in a Result, and its
+	// placeholder in help text.
+	Name string
+	Help string
+	// The values the argument accepts, as for Option.Values.
+	Values []string
+	// The type of the argument, as for Option.Type.
+	Type     func(value string) error
+	Hint     Hint
+	Complete func(prefix string) []lexopt.Candidate
+	// Whether it's an error if the argument is missing.
+	Required bool
+	// Whether the argument takes all the remaining positional arguments.
+	// Only the last positional argument can be Multiple.
+	Multiple bool
+}
+
+// A command or subcommand.
+type Command struct {
+	// The name the command is invoked by. For subcommands this is what
+	// selects it.
+	Name string
+	// A short description of the command.
+	Help string
+	// A longer description for man pages and reference documentation.
+	// Paragraphs are separated by blank lines.
+	Description string
+	Options     []Option
+	Positionals []Positional
+	// A subcommand is selected by the first positional argument, if it
+	// matches a subcommand's name. Parsing then continues with the
+	// subcommand and doesn't return to this command.
+	Subcommands []*Command
+	// The exit statuses of the command, for man pages and reference
+	// documentation. If it's empty 0 means success and 1 failure.
+	ExitStatus []ExitStatus
+	// Look up the environment variables named by Option.Env. If it's nil
+	// the parent command's is used, and at the top os.LookupEnv(). Tests
+	// can use MapEnv().
+	LookupEnv func(key string) (string, bool)
+	// Configuration files to read settings from, if they exist, like
+	// /etc/app.ini and then ~/.config/app.ini. Later files override
+	// earlier ones. Files ending in .json are JSON objects, and anything
+	// else is INI, as written by result.WriteConfig(). Only the top-level
+	// command's are used.
+	ConfigFiles []string
+	// The key of a Value option that names another configuration file,
+	// like "config" for --config PATH. It's read after ConfigFiles, and
+	// unlike them it has to exist. It's found before parsing anything
+	// else, so it has to come before any subcommand. Only the top-level
+	// command's is used.
+	ConfigOption string
+}
+
+// A check for Option.Type that accepts the values lexopt.ParseValue[T]()
+// can parse.
+func TypeOf[T any]() func(value string) error {
+	return func(value string) error {
+		_, err := lexopt.ParseValue[T](value)
+		return err
+	}
+}
+
+// Whether the option has a default value.
+func (o *Option) hasDefault() bool {
+	return o.Default != "" || o.HasDefault
+}
+
+// An environment for Command.LookupEnv that has just the variables in env.
+func MapEnv(env map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+// An exit status and what it means.
+type ExitStatus struct {
+	Code int
+	Help string
+}
+
+var defaultExitStatus = []ExitStatus{
+	{0, "Success."},
+	{1, "An error occurred."},
+}
+
+func (c *Command) exitStatus() []ExitStatus {
+	if len(c.ExitStatus) == 0 {
+		return defaultExitStatus
+	}
+	return c.ExitStatus
+}
+
+// The paragraphs of the command's Description.
+func (c *Command) paragraphs() []string {
+	var paragraphs []string
+	for _, paragraph := range strings.Split(c.Description, "\n\n") {
+		if paragraph = strings.Join(strings.Fields(paragraph), " "); paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	return paragraphs
+}
+
+// The name an option is stored under.
+func (o *Option) key() string {
+	if o.Name != "" {
+		return o.Name
+	} else if o.Long != "" {
+		return o.Long
+	} else {
+		return string(o.Short)
+	}
+}
+
+func (c *Command) lookupShort(short rune) *Option {
+	for i := range c.Options {
+		if c.Options[i].Short == short {
+			return &c.Options[i]
+		}
+	}
+	return nil
+}
+
+func (c *Command) lookupLong(long string) *Option {
+	for i := range c.Options {
+		if c.Options[i].Long != "" && c.Options[i].Long == long {
+			return &c.Options[i]
+		}
+	}
+	return nil
+}
+
+// Look up --no-option for a Negatable option.
+func (c *Command) lookupNegated(long string) *Option {
+	if name, ok := strings.CutPrefix(long, "no-"); ok {
+		if option := c.lookupLong(name); option != nil && option.Kind == Negatable {
+			return option
+		}
+	}
+	return nil
+}
+
+// The spellings of all the command's options.
+func (c *Command) optionNames() []string {
+	var names []string
+	for i := range c.Options {
+		names = append(names, c.Options[i].spellings()...)
+	}
+	return names
+}
+
+func (c *Command) subcommandNames() []string {
+	names := make([]string, len(c.Subcommands))
+	for i, sub := range c.Subcommands {
+		names[i] = sub.Name
+	}
+	return names
+}
+
+func (c *Command) lookupSubcommand(name string) *Command {
+	for _, sub := range c.Subcommands {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// Parse the command line.
+//
+// Options may appear anywhere among the positional arguments. Once a
+// subcommand is found the rest of the command line belongs to it, and its
+// Result is available as result.Subcommand. The subcommand is parsed with
+// p.Subparser(), so it has options of its own even if p was configured
+// with StopAtFirstPositional.
+//
+// Options that aren't on the command line are taken from their Env
+// variable, then from the configuration files, and then from their
+// Default, in that order of preference. The configuration file from
+// ConfigOption is found first, in a scan over a clone of p.
+//
+// # Errors
+//
+// Errors from the parser are returned as-is. Unknown options and extra
+// positional arguments are reported with parser.Unexpected(), with
+// suggestions for misspelled options. A positional argument where only a
+// subcommand could go is an ErrorUnknownSubcommand, and a missing required
+// positional argument is an ErrorCustom. A bad value from an environment
+// variable is an ErrorParsingFailed with the variable as its Option, like
+// $MYAPP_TOKEN, and no Span, and the same goes for a configuration file,
+// with an Option like app.ini:3: jobs. A configuration file that can't be
+// read is an ErrorCustom, or for the one from ConfigOption, an
+// ErrorParsingFailed that points at its path.
+func (c *Command) Parse(p *lexopt.Parser) (*Result, lexopt.Error) {
+	config, err := c.loadConfig(p)
+	if err != nil {
+		return nil, err
+	}
+	r := newResult(c, nil)
+	r.config = config
+	if err := c.parse(p, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Parse into r. If there's an error r has what was found before it.
+func (c *Command) parse(p *lexopt.Parser, r *Result) lexopt.Error {
+	positional := 0
+	seenPositional := false
+	for {
+		arg, ok, err := p.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		optionSpan := p.Span()
+		var option *Option
+		var used string
+		negated := false
+		if short, ok := arg.(lexopt.ArgShort); ok {
+			option = c.lookupShort(short.A)
+			used = fmt.Sprintf("-%c", short.A)
+		} else if long, ok := arg.(lexopt.ArgLong); ok {
+			option = c.lookupLong(long.A)
+			if option == nil {
+				option = c.lookupNegated(long.A)
+				negated = option != nil
+			}
+			used = "--" + long.A
+		} else if value, ok := arg.(lexopt.ArgValue); ok {
+			if !seenPositional {
+				if sub := c.lookupSubcommand(value.A); sub != nil {
+					r.Subcommand = newResult(sub, r)
+					// With a parser of its own, as for any subcommand, it has
+					// options even if p stopped at its first positional
+					// argument.
+					var commands lexopt.Dispatcher
+					commands.Handle(sub.Name, func(p *lexopt.Parser) lexopt.Error {
+						return sub.parse(p, r.Subcommand)
+					})
+					if err := commands.Dispatch(p, sub.Name); err != nil {
+						return err
+					}
+					if err := c.parseSettings(r); err != nil {
+						return err
+					}
+					return c.checkDefaults(r)
+				}
+			}
+			if positional >= len(c.Positionals) {
+				if !seenPositional && len(c.Subcommands) > 0 {
+					span := p.Span()
+					return &lexopt.ErrorUnknownSubcommand{
+						A:           value.A,
+						Span:        &span,
+						Suggestions: lexopt.Suggest(value.A, c.subcommandNames()),
+					}
+				}
+				return p.Unexpected(arg)
+			}
+			pos := &c.Positionals[positional]
+			if err := checkValue(pos.Values, pos.Type, value.A); err != nil {
+				return &lexopt.ErrorParsingFailed{Value: value.A, Error2: err, Span: &optionSpan}
+			}
+			r.add(pos.Name, occurrence{value: value.A, span: optionSpan})
+			seenPositional = true
+			if !pos.Multiple {
+				positional++
+			}
+			continue
+		}
+		if option == nil {
+			return p.Unexpected(arg, c.optionNames()...)
+		}
+		if option.Kind == Value {
+			value, err := p.Value()
+			if err != nil {
+				return err
+			}
+			if err := checkValue(option.Values, option.Type, value); err != nil {
+				return p.ParsingFailed(value, err)
+			}
+			r.add(option.key(), occurrence{
+				option:     used,
+				optionSpan: optionSpan,
+				value:      value,
+				span:       p.Span(),
+			})
+		} else if option.Kind == OptionalValue {
+			o := occurrence{option: used, optionSpan: optionSpan, value: option.Implicit, span: optionSpan}
+			if value, ok := p.OptionalValue(); ok {
+				if err := checkValue(option.Values, option.Type, value); err != nil {
+					return p.ParsingFailed(value, err)
+				}
+				o.value = value
+				o.span = p.Span()
+			} else if err := checkDetached(p, option, used, optionSpan); err != nil {
+				return err
+			} else if option.Type != nil {
+				if err := option.Type(option.Implicit); err != nil {
+					return p.ParsingFailed(option.Implicit, err)
+				}
+			}
+			r.add(option.key(), o)
+		} else if option.Kind == Negatable {
+			o := occurrence{option: used, optionSpan: optionSpan, value: strconv.FormatBool(!negated), span: optionSpan}
+			// Only --option=value, since --option value would swallow a
+			// positional argument. --no-option=value is left for Next() to
+			// complain about.
+			if _, ok := arg.(lexopt.ArgLong); ok && !negated {
+				if value, ok := p.OptionalValue(); ok {
+					on, err := lexopt.ParseValue[bool](value)
+					if err != nil {
+						return p.ParsingFailed(value, err)
+					}
+					o.value = strconv.FormatBool(on)
+					o.span = p.Span()
+				}
+			}
+			r.add(option.key(), o)
+		} else if option.Kind == Count {
+			o := occurrence{option: used, optionSpan: optionSpan, span: optionSpan}
+			if _, ok := arg.(lexopt.ArgLong); ok && option.Decrements == "" {
+				if value, ok := p.OptionalValue(); ok {
+					if err := checkLevel(r.Command, option, value); err != nil {
+						return p.ParsingFailed(value, err)
+					}
+					o.value = value
+					o.span = p.Span()
+				}
+			}
+			r.add(option.key(), o)
+		} else {
+			r.add(option.key(), occurrence{option: used, optionSpan: optionSpan, span: optionSpan})
+		}
+	}
+
+	if err := c.parseSettings(r); err != nil {
+		return err
+	}
+	if err := c.checkDefaults(r); err != nil {
+		return err
+	}
+	for i := range c.Positionals {
+		pos := &c.Positionals[i]
+		if pos.Required && len(r.values[pos.Name]) == 0 {
+			return &lexopt.ErrorCustom{A: fmt.Errorf("missing argument %v", pos.Name)}
+		}
+	}
+	return nil
+}
+
+// Fill in the options that weren't on the command line from the
+// environment, then the configuration files.
+func (c *Command) parseSettings(r *Result) lexopt.Error {
+	if err := c.parseEnv(r); err != nil {
+		return err
+	}
+	return c.parseConfig(r)
+}
+
+// Fill in the options that weren't on the command line from their
+// environment variables.
+func (c *Command) parseEnv(r *Result) lexopt.Error {
+	for i := range c.Options {
+		option := &c.Options[i]
+		if option.Env == "" || len(r.values[option.key()]) > 0 {
+			continue
+		}
+		value, ok := r.lookupEnv(option.Env)
+		if !ok || value == "" {
+			continue
+		}
+		o := occurrence{option: "$" + option.Env, from: Source{Kind: SourceEnv, Env: option.Env}}
+		if err := addSetting(r, option, o, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add a value for an option that didn't come from the command line, after
+// checking it as parse() would. o has the option and the source.
+func addSetting(r *Result, option *Option, o occurrence, value string) lexopt.Error {
+	// Before anything on the command line, for result.Level().
+	o.optionSpan = lexopt.Span{Index: -1}
+	o.value = value
+	var err error
+	if option.Kind == Flag || option.Kind == Negatable {
+		var on bool
+		if on, err = lexopt.ParseValue[bool](value); err == nil && option.Kind == Flag && !on {
+			return nil
+		}
+		o.value = strconv.FormatBool(on)
+		if option.Kind == Flag {
+			o.value = ""
+		}
+	} else if option.Kind == Count {
+		err = checkLevel(r.Command, option, value)
+		if err == nil && option.Decrements != "" {
+			o.value = ""
+			n, _ := strconv.Atoi(value)
+			for range n {
+				r.add(option.key(), o)
+			}
+			return nil
+		}
+	} else {
+		err = checkValue(option.Values, option.Type, value)
+	}
+	if err != nil {
+		return &lexopt.ErrorParsingFailed{Option: &o.option, Index: -1, Value: value, Error2: err}
+	}
+	r.add(option.key(), o)
+	return nil
+}
+
+// Catch --color never, where never is one of the values of an
+// OptionalValue option but would be taken as a positional argument.
+func checkDetached(p *lexopt.Parser, option *Option, used string, optionSpan lexopt.Span) lexopt.Error {
+	raw, ok := p.TryRawArgs()
+	if !ok || len(option.Values) == 0 {
+		return nil
+	}
+	value, ok := raw.Peek()
+	if !ok || !slices.Contains(option.Values, value) {
+		return nil
+	}
+	attached := used + value
+	if strings.HasPrefix(used, "--") {
+		attached = used + "=" + value
+	}
+	// Its span, which is only optionSpan.Index + 1 if neither came from a
+	// response file.
+	next := p.Clone()
+	next.Next()
+	span := next.Span()
+	return &lexopt.ErrorParsingFailed{
+		Option: &used,
+		Index:  optionSpan.Index,
+		Value:  value,
+		Error2: fmt.Errorf("the value has to be attached, as in '%v'", attached),
+		Span:   &span,
+	}
+}
+
+// Check an explicit level for a Count option. It's only negative if other
+// options of cmd count it down that far.
+func checkLevel(cmd *Command, option *Option, value string) error {
+	level, err := lexopt.ParseValue[int](value)
+	if err != nil {
+		return err
+	}
+	lowest := 0
+	for i := range cmd.Options {
+		if o := &cmd.Options[i]; o.Kind == Count && o.Decrements == option.key() {
+			if o.Max == 0 {
+				lowest = math.MinInt
+			} else {
+				lowest = min(lowest, -o.Max)
+			}
+		}
+	}
+	if level < lowest && lowest == 0 {
+		return fmt.Errorf("level can't be negative")
+	} else if level < lowest {
+		return fmt.Errorf("level can't be less than %v", lowest)
+	}
+	if option.Max > 0 && level > option.Max {
+		return fmt.Errorf("level can't be more than %v", option.Max)
+	}
+	return nil
+}
+
+// Check a value against a list of possible values, if there is one.
+func checkValue(values []string, typ func(value string) error, value string) error {
+	if len(values) > 0 && !slices.Contains(values, value) {
+		return fmt.Errorf("possible values: %v", strings.Join(values, ", "))
+	}
+	if typ != nil {
+		return typ(value)
+	}
+	return nil
+}
+
+// Check the Default of every option that wasn't set some other way.
+func (c *Command) checkDefaults(r *Result) lexopt.Error {
+	for i := range c.Options {
+		option := &c.Options[i]
+		if option.Type == nil || option.Kind != Value && option.Kind != OptionalValue {
+			continue
+		}
+		if len(r.values[option.key()]) == 0 && option.hasDefault() {
+			if err := option.Type(option.Default); err != nil {
+				name := option.displayName()
+				return &lexopt.ErrorParsingFailed{Option: &name, Index: -1, Value: option.Default, Error2: err}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,675 @@
+package spec
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jcbhmr/go-lexopt"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(args string) *lexopt.Parser {
+	return lexopt.ParserFromArgs(slices.Values(strings.Fields(args)))
+}
+
+var install = &Command{
+	Name: "install",
+	Options: []Option{
+		{Short: 'j', Long: "jobs", Kind: Value, ValueName: "N", Default: "4"},
+		{Long: "root", Kind: Value, ValueName: "DIR"},
+	},
+	Positionals: []Positional{
+		{Name: "CRATE", Required: true, Multiple: true},
+	},
+}
+
+var cargo = &Command{
+	Name: "cargo",
+	Options: []Option{
+		{Short: 'v', Long: "verbose", Kind: Flag},
+		{Long: "color", Kind: Value, ValueName: "WHEN"},
+		{Name: "features", Short: 'F', Kind: Value},
+	},
+	Subcommands: []*Command{install},
+}
+
+func TestParse(t *testing.T) {
+	r, err := cargo.Parse(parse("-vv --color=never -Ffoo -F bar install hello -j8 world"))
+	require.Nil(t, err)
+	require.True(t, r.Flag("verbose"))
+	require.Equal(t, 2, r.Count("verbose"))
+	color, ok := r.String("color")
+	require.True(t, ok)
+	require.Equal(t, "never", color)
+	require.Equal(t, []string{"foo", "bar"}, r.Strings("features"))
+
+	sub := r.Subcommand
+	require.Equal(t, install, sub.Command)
+	jobs, err := Get[int](sub, "jobs")
+	require.Nil(t, err)
+	require.Equal(t, 8, jobs)
+	require.Equal(t, []string{"hello", "world"}, sub.Strings("CRATE"))
+	require.False(t, sub.Has("root"))
+	_, ok = sub.String("root")
+	require.False(t, ok)
+
+	r, err = install.Parse(parse("hello"))
+	require.Nil(t, err)
+	jobs, err = Get[int](r, "jobs")
+	require.Nil(t, err)
+	require.Equal(t, 4, jobs)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := cargo.Parse(parse("--bogus"))
+	require.Equal(t, &lexopt.ErrorUnexpectedOption{A: "--bogus", Span: &lexopt.Span{Index: 0, Start: 0, End: 7}}, err)
+
+	_, err = cargo.Parse(parse("unknown"))
+	require.Equal(t, &lexopt.ErrorUnknownSubcommand{A: "unknown", Span: &lexopt.Span{Index: 0, Start: 0, End: 7}}, err)
+
+	_, err = cargo.Parse(parse("--verbsoe"))
+	require.Equal(t, "invalid option '--verbsoe'; did you mean '--verbose'?", err.Error())
+	_, err = cargo.Parse(parse("instal"))
+	require.Equal(t, "unknown subcommand 'instal'; did you mean 'install'?", err.Error())
+	_, err = cargo.Parse(parse("install x --job=2"))
+	require.Equal(t, "invalid option '--job'; did you mean '--jobs'?", err.Error())
+
+	_, err = vcs.Parse(parse("commit a b c"))
+	require.Nil(t, err)
+	_, err = vcs.Parse(parse("remote add origin url extra"))
+	require.IsType(t, &lexopt.ErrorUnexpectedArgument{}, err)
+
+	_, err = cargo.Parse(parse("--color"))
+	require.IsType(t, &lexopt.ErrorMissingValue{}, err)
+
+	_, err = cargo.Parse(parse("install"))
+	require.Equal(t, "missing argument CRATE", err.Error())
+
+	r, err := cargo.Parse(parse("install hello --jobs=many"))
+	require.Nil(t, err)
+	_, err = Get[int](r.Subcommand, "jobs")
+	require.Equal(t, &lexopt.ErrorParsingFailed{
+		Option: ptr("--jobs"),
+		Index:  2,
+		Value:  "many",
+		Error2: strconv.ErrSyntax,
+		Span:   &lexopt.Span{Index: 2, Start: 7, End: 11},
+	}, err)
+
+	require.Panics(t, func() { r.Has("nonexistent") })
+
+	_, err = vcs.Parse(parse("--color=sometimes"))
+	require.Equal(t, "invalid value 'sometimes' for '--color': possible values: always, never, auto", err.Error())
+	_, err = vcs.Parse(parse("remote remove elsewhere"))
+	require.Equal(t, "cannot parse argument \"elsewhere\": possible values: origin, upstream", err.Error())
+}
+
+func TestType(t *testing.T) {
+	tool := &Command{
+		Name: "tool",
+		Options: []Option{
+			{Short: 'j', Long: "jobs", Kind: Value, Type: TypeOf[int](), Default: "1", Env: "TOOL_JOBS"},
+			{Short: 'O', Kind: OptionalValue, Type: TypeOf[uint8](), Implicit: "1"},
+			{Long: "prefix", Kind: Value, HasDefault: true},
+			{Long: "name", Kind: Value},
+		},
+		Positionals: []Positional{{Name: "COUNT", Type: TypeOf[uint]()}},
+		LookupEnv:   MapEnv(nil),
+	}
+	r, err := tool.Parse(parse("-j 4 -O 7"))
+	require.Nil(t, err)
+	jobs, err := Get[int](r, "jobs")
+	require.Nil(t, err)
+	require.Equal(t, 4, jobs)
+	level, _ := Get[uint8](r, "O")
+	require.Equal(t, uint8(1), level)
+
+	// Bad values are caught by Parse(), not later by Get().
+	_, err = tool.Parse(parse("--jobs=many"))
+	require.Equal(t, &lexopt.ErrorParsingFailed{
+		Option: ptr("--jobs"),
+		Index:  0,
+		Value:  "many",
+		Error2: strconv.ErrSyntax,
+		Span:   &lexopt.Span{Index: 0, Start: 7, End: 11},
+	}, err)
+	_, err = tool.Parse(parse("-O300"))
+	require.Equal(t, "invalid value '300' for '-O': value out of range", err.Error())
+	_, err = tool.Parse(parse("-- -1"))
+	require.Equal(t, "cannot parse argument \"-1\": invalid syntax", err.Error())
+	tool.LookupEnv = MapEnv(map[string]string{"TOOL_JOBS": "all"})
+	_, err = tool.Parse(parse(""))
+	require.Equal(t, "invalid value 'all' for '$TOOL_JOBS': invalid syntax", err.Error())
+	tool.LookupEnv = MapEnv(nil)
+
+	// So are bad defaults, but only when they're used.
+	tool.Options[0].Default = "lots"
+	_, err = tool.Parse(parse("-j2"))
+	require.Nil(t, err)
+	_, err = tool.Parse(parse(""))
+	require.Equal(t, "invalid value 'lots' for '--jobs': invalid syntax", err.Error())
+	tool.Options[0].Default = "1"
+
+	// An empty Default only counts with HasDefault.
+	r, err = tool.Parse(parse(""))
+	require.Nil(t, err)
+	prefix, ok := r.String("prefix")
+	require.True(t, ok)
+	require.Equal(t, "", prefix)
+	require.Equal(t, []string{""}, r.Strings("prefix"))
+	_, ok = r.String("name")
+	require.False(t, ok)
+	source, _ := r.Source("prefix")
+	require.Equal(t, Source{Kind: SourceDefault}, source)
+	require.Contains(t, tool.HelpText(80), `(default: "")`)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestNegatable(t *testing.T) {
+	r, err := hello.Parse(parse("world"))
+	require.Nil(t, err)
+	require.True(t, r.Flag("color"))
+	require.False(t, r.Has("color"))
+
+	r, err = hello.Parse(parse("--no-color world --color --no-color"))
+	require.Nil(t, err)
+	require.False(t, r.Flag("color"))
+	require.Equal(t, []string{"false", "true", "false"}, r.Strings("color"))
+
+	r, err = hello.Parse(parse("--no-color --color=1 world"))
+	require.Nil(t, err)
+	require.True(t, r.Flag("color"))
+	color, err := Get[bool](r, "color")
+	require.Nil(t, err)
+	require.True(t, color)
+	r, err = hello.Parse(parse("--color=false world"))
+	require.Nil(t, err)
+	require.False(t, r.Flag("color"))
+
+	// The value has to be attached.
+	r, err = hello.Parse(parse("--no-color --color false"))
+	require.Nil(t, err)
+	require.True(t, r.Flag("color"))
+	require.Equal(t, []string{"false"}, r.Strings("THING"))
+
+	_, err = hello.Parse(parse("--color=sometimes world"))
+	require.Equal(t, `invalid value 'sometimes' for '--color': invalid syntax`, err.Error())
+	require.Equal(t, &lexopt.Span{Index: 0, Start: 8, End: 17}, err.(*lexopt.ErrorParsingFailed).Span)
+	_, err = hello.Parse(parse("--no-color=false world"))
+	require.Equal(t, &lexopt.ErrorUnexpectedValue{Option: "--no-color", Value: "false", Span: &lexopt.Span{Index: 0, Start: 11, End: 16}}, err)
+	_, err = hello.Parse(parse("--no-colr world"))
+	require.Equal(t, "invalid option '--no-colr'; did you mean '--no-color'?", err.Error())
+	// Only Negatable options get a --no- form.
+	_, err = hello.Parse(parse("--no-shout world"))
+	require.IsType(t, &lexopt.ErrorUnexpectedOption{}, err)
+}
+
+var logs = &Command{
+	Name: "logs",
+	Options: []Option{
+		{Short: 'v', Long: "verbose", Kind: Count, Max: 3, Default: "1"},
+		{Short: 'q', Long: "quiet", Kind: Count, Max: 1, Decrements: "verbose"},
+		{Long: "debug", Kind: Count},
+	},
+	Positionals: []Positional{
+		{Name: "FILE", Multiple: true},
+	},
+}
+
+func TestCount(t *testing.T) {
+	level := func(args string) int {
+		t.Helper()
+		r, err := logs.Parse(parse(args))
+		require.Nil(t, err)
+		return r.Level("verbose")
+	}
+	require.Equal(t, 1, level(""))
+	require.Equal(t, 2, level("-v"))
+	require.Equal(t, 3, level("-vv"))
+	require.Equal(t, 3, level("-vvvvvv"))
+	require.Equal(t, 3, level("-v a -v b --verbose"))
+	require.Equal(t, 0, level("-q"))
+	require.Equal(t, -1, level("-qqq"))
+	require.Equal(t, 2, level("-vv -q"))
+	require.Equal(t, 0, level("-vqqv -q"))
+	require.Equal(t, 3, level("-qq --verbose=3"))
+	require.Equal(t, 2, level("--verbose=3 -q"))
+	require.Equal(t, 0, level("-vvv --verbose=0"))
+
+	r, err := logs.Parse(parse("--debug --debug=7 --debug"))
+	require.Nil(t, err)
+	require.Equal(t, 8, r.Level("debug"))
+	require.Equal(t, 3, r.Count("debug"))
+
+	_, err = logs.Parse(parse("--verbose=4"))
+	require.Equal(t, &lexopt.ErrorParsingFailed{Option: ptr("--verbose"), Index: 0, Value: "4", Error2: err.(*lexopt.ErrorParsingFailed).Error2, Span: &lexopt.Span{Index: 0, Start: 10, End: 11}}, err)
+	require.Equal(t, "invalid value '4' for '--verbose': level can't be more than 3", err.Error())
+	// A level goes negative only as far as -q can take it.
+	require.Equal(t, -1, level("--verbose=-1"))
+	_, err = logs.Parse(parse("--verbose=-2"))
+	require.Equal(t, "invalid value '-2' for '--verbose': level can't be less than -1", err.Error())
+	_, err = logs.Parse(parse("--debug=-1"))
+	require.Equal(t, "invalid value '-1' for '--debug': level can't be negative", err.Error())
+	_, err = logs.Parse(parse("--verbose=lots"))
+	require.Equal(t, `invalid value 'lots' for '--verbose': invalid syntax`, err.Error())
+	// Only the option being counted takes an explicit level.
+	_, err = logs.Parse(parse("--quiet=2"))
+	require.IsType(t, &lexopt.ErrorUnexpectedValue{}, err)
+	// The level still can't be given as the next argument.
+	r, err = logs.Parse(parse("--verbose 3"))
+	require.Nil(t, err)
+	require.Equal(t, 2, r.Level("verbose"))
+	require.Equal(t, []string{"3"}, r.Strings("FILE"))
+
+	require.Panics(t, func() { r.Level("FILE") })
+}
+
+var cc = &Command{
+	Name: "cc",
+	Options: []Option{
+		{Long: "color", Kind: OptionalValue, ValueName: "WHEN", Values: []string{"always", "never", "auto"}, Implicit: "always", Default: "auto"},
+		{Short: 'O', Kind: OptionalValue, ValueName: "LEVEL", Implicit: "1", Default: "0"},
+		{Short: 'g', Kind: Flag},
+	},
+	Positionals: []Positional{
+		{Name: "FILE", Multiple: true},
+	},
+}
+
+func TestOptionalValue(t *testing.T) {
+	r, err := cc.Parse(parse("a.c"))
+	require.Nil(t, err)
+	color, _ := r.String("color")
+	require.Equal(t, "auto", color)
+	level, _ := r.String("O")
+	require.Equal(t, "0", level)
+
+	r, err = cc.Parse(parse("--color -O a.c"))
+	require.Nil(t, err)
+	color, _ = r.String("color")
+	require.Equal(t, "always", color)
+	level, _ = r.String("O")
+	require.Equal(t, "1", level)
+
+	r, err = cc.Parse(parse("--color=never -O2 -gO3 -O=s -O a.c 3"))
+	require.Nil(t, err)
+	require.Equal(t, []string{"never"}, r.Strings("color"))
+	require.Equal(t, []string{"2", "3", "s", "1"}, r.Strings("O"))
+	require.Equal(t, []string{"a.c", "3"}, r.Strings("FILE"))
+
+	_, err = cc.Parse(parse("--color=sometimes"))
+	require.Equal(t, "invalid value 'sometimes' for '--color': possible values: always, never, auto", err.Error())
+
+	// A detached value would silently become a positional argument.
+	p := parse("a.c --color never")
+	_, err = cc.Parse(p)
+	require.Equal(t, &lexopt.ErrorParsingFailed{
+		Option: ptr("--color"),
+		Index:  1,
+		Value:  "never",
+		Error2: err.(*lexopt.ErrorParsingFailed).Error2,
+		Span:   &lexopt.Span{Index: 2, Start: 0, End: 5},
+	}, err)
+	require.Equal(t, "invalid value 'never' for '--color': the value has to be attached, as in '--color=never'", err.Error())
+	require.Equal(t, "error: invalid value 'never' for '--color': the value has to be attached, as in '--color=never'\n  a.c --color never\n              ^^^^^\n", p.RenderError(err, false))
+	// But not if it's not one of the values, or it's clearly positional.
+	_, err = cc.Parse(parse("--color a.c"))
+	require.Nil(t, err)
+	_, err = cc.Parse(parse("--color -- never"))
+	require.Nil(t, err)
+
+	help := cc.HelpText(80)
+	require.Contains(t, help, "\n      --color[=WHEN]  (possible values: always, never, auto, default: auto)\n")
+	require.Contains(t, help, "\n  -O[LEVEL]  ")
+}
+
+func TestEnv(t *testing.T) {
+	tool := &Command{
+		Name: "tool",
+		Options: []Option{
+			{Long: "token", Kind: Value, Env: "MYAPP_TOKEN"},
+			{Short: 'j', Long: "jobs", Kind: Value, Env: "MYAPP_JOBS", Default: "1"},
+			{Long: "mode", Kind: Value, Values: []string{"fast", "slow"}, Env: "MYAPP_MODE"},
+			{Long: "debug", Kind: Flag, Env: "MYAPP_DEBUG"},
+			{Long: "color", Kind: Negatable, Default: "true", Env: "MYAPP_COLOR"},
+			{Short: 'v', Long: "verbose", Kind: Count, Max: 3, Env: "MYAPP_VERBOSE"},
+			{Short: 'q', Kind: Count, Decrements: "verbose", Env: "MYAPP_QUIET"},
+		},
+		Subcommands: []*Command{
+			{Name: "run", Options: []Option{{Long: "target", Kind: Value, Env: "MYAPP_TARGET"}}},
+		},
+	}
+	env := map[string]string{
+		"MYAPP_TOKEN":   "secret",
+		"MYAPP_JOBS":    "8",
+		"MYAPP_DEBUG":   "1",
+		"MYAPP_COLOR":   "false",
+		"MYAPP_VERBOSE": "2",
+		"MYAPP_TARGET":  "x86",
+	}
+	tool.LookupEnv = MapEnv(env)
+
+	r, err := tool.Parse(parse("-q run"))
+	require.Nil(t, err)
+	token, _ := r.String("token")
+	require.Equal(t, "secret", token)
+	jobs, err := Get[int](r, "jobs")
+	require.Nil(t, err)
+	require.Equal(t, 8, jobs)
+	require.False(t, r.Has("mode"))
+	require.True(t, r.Flag("debug"))
+	require.False(t, r.Flag("color"))
+	require.Equal(t, 1, r.Level("verbose"))
+	// Subcommands share the environment.
+	target, _ := r.Subcommand.String("target")
+	require.Equal(t, "x86", target)
+
+	// The command line wins.
+	r, err = tool.Parse(parse("--token=other -j2 --color -v"))
+	require.Nil(t, err)
+	require.Equal(t, []string{"other"}, r.Strings("token"))
+	jobs, _ = Get[int](r, "jobs")
+	require.Equal(t, 2, jobs)
+	require.True(t, r.Flag("color"))
+	require.Equal(t, 1, r.Level("verbose"))
+
+	// Empty and false values are as good as unset.
+	tool.LookupEnv = MapEnv(map[string]string{"MYAPP_TOKEN": "", "MYAPP_DEBUG": "false"})
+	r, err = tool.Parse(parse(""))
+	require.Nil(t, err)
+	require.False(t, r.Has("token"))
+	require.False(t, r.Flag("debug"))
+	jobs, _ = Get[int](r, "jobs")
+	require.Equal(t, 1, jobs)
+
+	// Errors say where the value came from.
+	tool.LookupEnv = MapEnv(map[string]string{"MYAPP_JOBS": "many"})
+	p := parse("")
+	r, err = tool.Parse(p)
+	require.Nil(t, err)
+	_, err = Get[int](r, "jobs")
+	require.Equal(t, `invalid value 'many' for '$MYAPP_JOBS': invalid syntax`, err.Error())
+	require.Nil(t, err.(*lexopt.ErrorParsingFailed).Span)
+	require.Equal(t, -1, err.(*lexopt.ErrorParsingFailed).Index)
+	require.Equal(t, "error: "+err.Error()+"\n", p.RenderError(err, false))
+
+	tool.LookupEnv = MapEnv(map[string]string{"MYAPP_MODE": "medium"})
+	_, err = tool.Parse(parse(""))
+	require.Equal(t, &lexopt.ErrorParsingFailed{Option: ptr("$MYAPP_MODE"), Index: -1, Value: "medium", Error2: err.(*lexopt.ErrorParsingFailed).Error2}, err)
+	require.Equal(t, "invalid value 'medium' for '$MYAPP_MODE': possible values: fast, slow", err.Error())
+	tool.LookupEnv = MapEnv(map[string]string{"MYAPP_DEBUG": "yes"})
+	_, err = tool.Parse(parse(""))
+	require.Equal(t, `invalid value 'yes' for '$MYAPP_DEBUG': invalid syntax`, err.Error())
+	tool.LookupEnv = MapEnv(map[string]string{"MYAPP_VERBOSE": "9"})
+	_, err = tool.Parse(parse(""))
+	require.Equal(t, "invalid value '9' for '$MYAPP_VERBOSE': level can't be more than 3", err.Error())
+	tool.LookupEnv = MapEnv(map[string]string{"MYAPP_TARGET": ""})
+	r, err = tool.Parse(parse("run"))
+	require.Nil(t, err)
+	require.False(t, r.Subcommand.Has("target"))
+
+	// A level for an option that Decrements another counts down that
+	// many times.
+	tool.LookupEnv = MapEnv(map[string]string{"MYAPP_QUIET": "3"})
+	r, err = tool.Parse(parse(""))
+	require.Nil(t, err)
+	require.Equal(t, -3, r.Level("verbose"))
+	require.Equal(t, 3, r.Count("q"))
+	tool.LookupEnv = MapEnv(map[string]string{"MYAPP_VERBOSE": "2", "MYAPP_QUIET": "3"})
+	r, err = tool.Parse(parse(""))
+	require.Nil(t, err)
+	require.Equal(t, -1, r.Level("verbose"))
+	tool.LookupEnv = MapEnv(map[string]string{"MYAPP_QUIET": "-1"})
+	_, err = tool.Parse(parse(""))
+	require.Equal(t, "invalid value '-1' for '$MYAPP_QUIET': level can't be negative", err.Error())
+}
+
+func TestSource(t *testing.T) {
+	tool := &Command{
+		Name: "tool",
+		Options: []Option{
+			{Long: "token", Kind: Value, Env: "MYAPP_TOKEN"},
+			{Short: 'j', Long: "jobs", Kind: Value, Default: "1"},
+			{Short: 'I', Long: "include", Kind: Value},
+			{Long: "name", Kind: Value},
+			{Long: "debug", Kind: Flag},
+			{Long: "color", Kind: Negatable, Default: "true"},
+			{Short: 'v', Long: "verbose", Kind: Count},
+		},
+		Subcommands: []*Command{
+			{Name: "run", Options: []Option{{Long: "target", Kind: Value}, {Long: "dry-run", Kind: Flag}}},
+		},
+		LookupEnv: MapEnv(map[string]string{"MYAPP_TOKEN": "s3cret"}),
+	}
+	r, err := tool.Parse(parse("-vv -I a --include=b#c --no-color run --target=x86"))
+	require.Nil(t, err)
+
+	source, ok := r.Source("token")
+	require.True(t, ok)
+	require.Equal(t, Source{Kind: SourceEnv, Env: "MYAPP_TOKEN"}, source)
+	require.Equal(t, "$MYAPP_TOKEN", source.String())
+	source, _ = r.Source("jobs")
+	require.Equal(t, Source{Kind: SourceDefault}, source)
+	source, _ = r.Source("include")
+	require.Equal(t, Source{Kind: SourceCommandLine, Index: 3}, source)
+	require.Equal(t, "argv[4]", source.String())
+	_, ok = r.Source("name")
+	require.False(t, ok)
+	source, _ = r.Source("debug")
+	require.Equal(t, "default", source.String())
+	source, _ = r.Source("color")
+	require.Equal(t, Source{Kind: SourceCommandLine, Index: 4}, source)
+	source, _ = r.Subcommand.Source("target")
+	require.Equal(t, Source{Kind: SourceCommandLine, Index: 6}, source)
+	require.Equal(t, "app.ini:12", Source{Kind: SourceConfig, Path: "app.ini", Line: 12}.String())
+
+	require.Equal(t, `token   = s3cret # $MYAPP_TOKEN
+jobs    = 1      # default
+include = a      # argv[2]
+include = "b#c"  # argv[4]
+debug   = false  # default
+color   = false  # argv[5]
+verbose = 2      # argv[1]
+
+[run]
+target  = x86   # argv[7]
+dry-run = false # default
+`, r.ConfigText())
+
+	// A level from several places lists all of them, from the last
+	// explicit level on.
+	counter := &Command{
+		Name: "counter",
+		Options: []Option{
+			{Short: 'v', Long: "verbose", Kind: Count, Env: "MYAPP_VERBOSE"},
+			{Short: 'q', Long: "quiet", Kind: Count, Decrements: "verbose"},
+		},
+		LookupEnv: MapEnv(map[string]string{"MYAPP_VERBOSE": "3"}),
+	}
+	r, err = counter.Parse(parse("-q -qq"))
+	require.Nil(t, err)
+	require.Equal(t, 0, r.Level("verbose"))
+	source, _ = r.Source("verbose")
+	require.Equal(t, "$MYAPP_VERBOSE", source.String())
+	require.Equal(t, "verbose = 0 # $MYAPP_VERBOSE, argv[1], argv[2]\n", r.ConfigText())
+	r, err = counter.Parse(parse("-v --verbose=1 -v"))
+	require.Nil(t, err)
+	require.Equal(t, "verbose = 2 # argv[2], argv[3]\n", r.ConfigText())
+}
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+	system := write("system.json", `{
+  "jobs": 2,
+  "include": ["a", "b"],
+  "debug": true,
+  "run": {"target": "arm"}
+}`)
+	user := write("user.ini", `# Settings for tool.
+jobs = 4 ; more
+name = "  spaced # out "
+verbose = 2
+
+[run]
+target = x86
+`)
+	tool := &Command{
+		Name: "tool",
+		Options: []Option{
+			{Long: "config", Kind: Value},
+			{Short: 'j', Long: "jobs", Kind: Value, Default: "1", Env: "MYAPP_JOBS"},
+			{Short: 'I', Long: "include", Kind: Value},
+			{Long: "name", Kind: Value},
+			{Long: "debug", Kind: Flag},
+			{Short: 'v', Long: "verbose", Kind: Count},
+		},
+		Subcommands: []*Command{
+			{Name: "run", Options: []Option{{Long: "target", Kind: Value, Values: []string{"arm", "x86"}}}},
+		},
+		ConfigFiles:  []string{system, user, filepath.Join(dir, "missing.ini")},
+		ConfigOption: "config",
+		LookupEnv:    MapEnv(nil),
+	}
+
+	// Later files win, key by key.
+	r, err := tool.Parse(parse("run"))
+	require.Nil(t, err)
+	jobs, _ := Get[int](r, "jobs")
+	require.Equal(t, 4, jobs)
+	require.Equal(t, []string{"a", "b"}, r.Strings("include"))
+	name, _ := r.String("name")
+	require.Equal(t, "  spaced # out ", name)
+	require.True(t, r.Flag("debug"))
+	require.Equal(t, 2, r.Level("verbose"))
+	target, _ := r.Subcommand.String("target")
+	require.Equal(t, "x86", target)
+	source, _ := r.Source("jobs")
+	require.Equal(t, Source{Kind: SourceConfig, Path: user, Line: 2}, source)
+	source, _ = r.Source("include")
+	require.Equal(t, Source{Kind: SourceConfig, Path: system, Line: 3}, source)
+
+	// Then the file from --config, then the environment, then the command
+	// line. The value of -I isn't mistaken for --config.
+	extra := write("extra.ini", "jobs = 6\ninclude = c\n")
+	r, err = tool.Parse(parse("-I --config --config " + extra + " run"))
+	require.Nil(t, err)
+	jobs, _ = Get[int](r, "jobs")
+	require.Equal(t, 6, jobs)
+	require.Equal(t, []string{"--config"}, r.Strings("include"))
+	require.Equal(t, 2, r.Level("verbose"))
+	r, err = tool.Parse(parse("-v"))
+	require.Nil(t, err)
+	require.Equal(t, 1, r.Level("verbose"))
+	tool.LookupEnv = MapEnv(map[string]string{"MYAPP_JOBS": "7"})
+	r, err = tool.Parse(parse("--config=" + extra))
+	require.Nil(t, err)
+	jobs, _ = Get[int](r, "jobs")
+	require.Equal(t, 7, jobs)
+	r, err = tool.Parse(parse("-j8 --config=" + extra))
+	require.Nil(t, err)
+	jobs, _ = Get[int](r, "jobs")
+	require.Equal(t, 8, jobs)
+	tool.LookupEnv = MapEnv(nil)
+
+	// What WriteConfig() writes can be read back.
+	r, err = tool.Parse(parse("--name=#;\" run"))
+	require.Nil(t, err)
+	dumped := write("dumped.ini", r.ConfigText())
+	tool.ConfigFiles = []string{dumped}
+	r2, err := tool.Parse(parse("run"))
+	require.Nil(t, err)
+	for _, key := range []string{"jobs", "include", "name", "debug", "verbose"} {
+		require.Equal(t, r.Strings(key), r2.Strings(key), key)
+	}
+	require.Equal(t, r.Level("verbose"), r2.Level("verbose"))
+	target, _ = r2.Subcommand.String("target")
+	require.Equal(t, "x86", target)
+
+	// So can the level of a Count option, including what -q took off it.
+	levels := &Command{
+		Name: "levels",
+		Options: []Option{
+			{Short: 'v', Long: "verbose", Kind: Count, Max: 3},
+			{Short: 'q', Long: "quiet", Kind: Count, Max: 2, Decrements: "verbose"},
+		},
+		ConfigFiles: []string{filepath.Join(dir, "levels.ini")},
+		LookupEnv:   MapEnv(nil),
+	}
+	for _, args := range []string{"", "-q", "-qqq", "-vvv -q", "--verbose=0 -qq", "-qq --verbose=2", "--verbose=-2"} {
+		r, err := levels.Parse(parse(args))
+		require.Nil(t, err, args)
+		write("levels.ini", r.ConfigText())
+		r2, err := levels.Parse(parse(""))
+		require.Nil(t, err, args)
+		require.Equal(t, r.Level("verbose"), r2.Level("verbose"), args)
+		require.False(t, r2.Has("quiet"), args)
+		require.NoError(t, os.Remove(levels.ConfigFiles[0]), args)
+	}
+
+	// Errors say where the value came from.
+	tool.ConfigFiles = []string{write("bad.json", "{\n  \"jobs\": \"many\"\n}")}
+	r, err = tool.Parse(parse(""))
+	require.Nil(t, err)
+	_, err = Get[int](r, "jobs")
+	require.Equal(t, "invalid value 'many' for '"+tool.ConfigFiles[0]+":2: jobs': invalid syntax", err.Error())
+	tool.ConfigFiles = []string{write("bad.ini", "[run]\ntarget = mips\n")}
+	_, err = tool.Parse(parse("run"))
+	require.Equal(t, "invalid value 'mips' for '"+tool.ConfigFiles[0]+":2: target': possible values: arm, x86", err.Error())
+
+	for _, test := range []struct{ name, content, message string }{
+		{"typo.ini", "\njbos = 3\n", ":2: unknown setting 'jbos'; did you mean 'jobs'?"},
+		{"section.ini", "[walk]\n\nspeed = 3\n", ":3: unknown subcommand 'walk'"},
+		{"line.ini", "jobs\n", ":1: expected key = value"},
+		{"quote.ini", "name = \"open\n", ":1: unterminated or invalid quoted value"},
+		{"null.json", "{\"jobs\": null}", ":1: expected a string, number or boolean"},
+		{"array.json", "[]", ":1: expected an object"},
+		{"trailing.json", "{}\n{}", ":2: unexpected data after the object"},
+		{"syntax.json", "{\n\"jobs\" 3}", ":2: invalid character '3' after object key"},
+	} {
+		path := write(test.name, test.content)
+		tool.ConfigFiles = []string{path}
+		_, err := tool.Parse(parse(""))
+		require.IsType(t, &lexopt.ErrorCustom{}, err, test.name)
+		require.Equal(t, path+test.message, err.Error(), test.name)
+	}
+
+	// Only the file from --config has to exist, and the error points at it.
+	tool.ConfigFiles = nil
+	p := parse("-v --config nowhere.ini")
+	_, err = tool.Parse(p)
+	require.Equal(t, "invalid value 'nowhere.ini' for '--config': open nowhere.ini: no such file or directory", err.Error())
+	require.Equal(t, 1, err.(*lexopt.ErrorParsingFailed).Index)
+	require.Equal(t, 2, err.(*lexopt.ErrorParsingFailed).Span.Index)
+}
+
+func TestStopAtFirstPositional(t *testing.T) {
+	config := lexopt.ParserConfig{StopAtFirstPositional: true}
+
+	// The subcommand has options of its own until its first positional
+	// argument.
+	r, err := cargo.Parse(parse("-v install --jobs=2 hello --root /opt").Configure(config))
+	require.Nil(t, err)
+	require.True(t, r.Flag("verbose"))
+	jobs, err := Get[int](r.Subcommand, "jobs")
+	require.Nil(t, err)
+	require.Equal(t, 2, jobs)
+	require.Equal(t, []string{"hello", "--root", "/opt"}, r.Subcommand.Strings("CRATE"))
+
+	// Or none at all after --.
+	r, err = cargo.Parse(parse("install -- --jobs=2").Configure(config))
+	require.Nil(t, err)
+	require.False(t, r.Subcommand.Has("jobs"))
+	require.Equal(t, []string{"--jobs=2"}, r.Subcommand.Strings("CRATE"))
+}
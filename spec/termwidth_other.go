@@ -0,0 +1,7 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package spec
+
+func ioctlWidth() int {
+	return 0
+}
@@ -0,0 +1,60 @@
+# bash completion for cargo
+
+_cargo() {
+    local cur prev cmd word i
+    COMPREPLY=()
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev=""
+    if ((COMP_CWORD > 0)); then
+        prev="${COMP_WORDS[COMP_CWORD-1]}"
+    fi
+    # bash splits --option=value into three words.
+    if [[ $cur == "=" ]]; then
+        cur=""
+    elif [[ $prev == "=" ]] && ((COMP_CWORD > 1)); then
+        prev="${COMP_WORDS[COMP_CWORD-2]}"
+    fi
+
+    cmd='cargo'
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        case "$cmd:$word" in
+            'cargo:--color' | 'cargo:-F')
+                if [[ ${COMP_WORDS[i+1]} == "=" ]]; then ((i += 2)); else ((i++)); fi ;;
+            'cargo:install') cmd='cargo__install' ;;
+            'cargo__install:-j' | 'cargo__install:--jobs' | 'cargo__install:--root')
+                if [[ ${COMP_WORDS[i+1]} == "=" ]]; then ((i += 2)); else ((i++)); fi ;;
+        esac
+    done
+
+    case "$cmd:$prev" in
+        'cargo:--color')
+            :
+            return ;;
+        'cargo:-F')
+            :
+            return ;;
+        'cargo__install:-j' | 'cargo__install:--jobs')
+            :
+            return ;;
+        'cargo__install:--root')
+            :
+            return ;;
+    esac
+
+    if [[ $cur == -* ]]; then
+        case "$cmd" in
+            'cargo') COMPREPLY=($(compgen -W '-v --verbose --color -F' -- "$cur")) ;;
+            'cargo__install') COMPREPLY=($(compgen -W '-j --jobs --root' -- "$cur")) ;;
+        esac
+        return
+    fi
+
+    case "$cmd" in
+        'cargo')
+            COMPREPLY+=($(compgen -W 'install' -- "$cur"))
+            ;;
+    esac
+}
+
+complete -F _cargo 'cargo'
@@ -0,0 +1,11 @@
+# fish completion for cargo
+
+complete -c 'cargo' -f
+
+complete -c 'cargo' -n 'not __fish_seen_subcommand_from install' -s 'v' -l 'verbose'
+complete -c 'cargo' -n 'not __fish_seen_subcommand_from install' -l 'color' -x
+complete -c 'cargo' -n 'not __fish_seen_subcommand_from install' -s 'F' -x
+complete -c 'cargo' -n 'not __fish_seen_subcommand_from install' -a 'install'
+
+complete -c 'cargo' -n '__fish_seen_subcommand_from install' -s 'j' -l 'jobs' -x
+complete -c 'cargo' -n '__fish_seen_subcommand_from install' -l 'root' -x
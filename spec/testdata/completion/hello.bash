@@ -0,0 +1,47 @@
+# bash completion for hello
+
+_hello() {
+    local cur prev cmd word i
+    COMPREPLY=()
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev=""
+    if ((COMP_CWORD > 0)); then
+        prev="${COMP_WORDS[COMP_CWORD-1]}"
+    fi
+    # bash splits --option=value into three words.
+    if [[ $cur == "=" ]]; then
+        cur=""
+    elif [[ $prev == "=" ]] && ((COMP_CWORD > 1)); then
+        prev="${COMP_WORDS[COMP_CWORD-2]}"
+    fi
+
+    cmd='hello'
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        case "$cmd:$word" in
+            'hello:-n' | 'hello:--number' | 'hello:--a-rather-long-option-name')
+                if [[ ${COMP_WORDS[i+1]} == "=" ]]; then ((i += 2)); else ((i++)); fi ;;
+        esac
+    done
+
+    case "$cmd:$prev" in
+        'hello:-n' | 'hello:--number')
+            :
+            return ;;
+        'hello:--a-rather-long-option-name')
+            :
+            return ;;
+    esac
+
+    if [[ $cur == -* ]]; then
+        case "$cmd" in
+            'hello') COMPREPLY=($(compgen -W '-n --number --shout --color --no-color --a-rather-long-option-name -h --help' -- "$cur")) ;;
+        esac
+        return
+    fi
+
+    case "$cmd" in
+    esac
+}
+
+complete -F _hello 'hello'
@@ -0,0 +1,9 @@
+# fish completion for hello
+
+complete -c 'hello' -f
+
+complete -c 'hello' -s 'n' -l 'number' -x -d 'How many times to print the greeting'
+complete -c 'hello' -l 'shout' -d 'Print the greeting in uppercase'
+complete -c 'hello' -l 'color' -l 'no-color' -d 'Color the greeting'
+complete -c 'hello' -l 'a-rather-long-option-name' -x -d 'An option whose name doesn'\''t fit in the column'
+complete -c 'hello' -s 'h' -l 'help' -d 'Print help'
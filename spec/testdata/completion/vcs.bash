@@ -0,0 +1,105 @@
+# bash completion for vcs
+
+_vcs_dynamic() {
+    local words=() word before i
+    # Rejoin --option=value, which bash splits into three words.
+    for ((i = 0; i <= COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        if ((i > 1)) && [[ $word == "=" && ${COMP_WORDS[i-1]} == -* || ${COMP_WORDS[i-1]} == "=" && ${COMP_WORDS[i-2]} == -* ]]; then
+            words[${#words[@]}-1]+="$word"
+        else
+            words+=("$word")
+        fi
+    done
+    # bash only replaces what's after the =.
+    before="${words[${#words[@]}-1]}"
+    before="${before:0:${#before}-${#cur}}"
+    while IFS= read -r word; do
+        COMPREPLY+=("${word#"$before"}")
+    done < <("${COMP_WORDS[0]}" __complete "$((${#words[@]} - 1))" "${words[@]}" 2>/dev/null | cut -f1)
+}
+
+_vcs() {
+    local cur prev cmd word i
+    COMPREPLY=()
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev=""
+    if ((COMP_CWORD > 0)); then
+        prev="${COMP_WORDS[COMP_CWORD-1]}"
+    fi
+    # bash splits --option=value into three words.
+    if [[ $cur == "=" ]]; then
+        cur=""
+    elif [[ $prev == "=" ]] && ((COMP_CWORD > 1)); then
+        prev="${COMP_WORDS[COMP_CWORD-2]}"
+    fi
+
+    cmd='vcs'
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        case "$cmd:$word" in
+            'vcs:-C' | 'vcs:--color')
+                if [[ ${COMP_WORDS[i+1]} == "=" ]]; then ((i += 2)); else ((i++)); fi ;;
+            'vcs:commit') cmd='vcs__commit' ;;
+            'vcs:remote') cmd='vcs__remote' ;;
+            'vcs__commit:-m' | 'vcs__commit:--message' | 'vcs__commit:-F' | 'vcs__commit:--file' | 'vcs__commit:--fixup')
+                if [[ ${COMP_WORDS[i+1]} == "=" ]]; then ((i += 2)); else ((i++)); fi ;;
+            'vcs__commit:-S' | 'vcs__commit:--gpg-sign' | 'vcs__commit:--cleanup')
+                if [[ ${COMP_WORDS[i+1]} == "=" ]]; then ((i += 2)); fi ;;
+            'vcs__remote:add') cmd='vcs__remote__add' ;;
+            'vcs__remote:remove') cmd='vcs__remote__remove' ;;
+        esac
+    done
+
+    case "$cmd:$prev" in
+        'vcs:-C')
+            compopt -o filenames 2>/dev/null; COMPREPLY+=($(compgen -d -- "$cur"))
+            return ;;
+        'vcs:--color')
+            COMPREPLY+=($(compgen -W 'always never auto' -- "$cur"))
+            return ;;
+        'vcs__commit:-m' | 'vcs__commit:--message')
+            :
+            return ;;
+        'vcs__commit:-F' | 'vcs__commit:--file')
+            compopt -o filenames 2>/dev/null; COMPREPLY+=($(compgen -f -- "$cur"))
+            return ;;
+        'vcs__commit:--fixup')
+            _vcs_dynamic
+            return ;;
+    esac
+
+    if [[ ${COMP_WORDS[COMP_CWORD]} == "=" || ${COMP_WORDS[COMP_CWORD-1]} == "=" ]]; then
+        case "$cmd:$prev" in
+            'vcs__commit:--cleanup')
+                COMPREPLY+=($(compgen -W 'strip whitespace verbatim' -- "$cur"))
+                return ;;
+        esac
+    fi
+
+    if [[ $cur == -* ]]; then
+        case "$cmd" in
+            'vcs') COMPREPLY=($(compgen -W '-C --color -v --verbose' -- "$cur")) ;;
+            'vcs__commit') COMPREPLY=($(compgen -W '-m --message -F --file --fixup -S --gpg-sign --cleanup' -- "$cur")) ;;
+            'vcs__remote__add') COMPREPLY=($(compgen -W '-f --fetch' -- "$cur")) ;;
+        esac
+        return
+    fi
+
+    case "$cmd" in
+        'vcs')
+            COMPREPLY+=($(compgen -W 'commit remote' -- "$cur"))
+            ;;
+        'vcs__commit')
+            compopt -o filenames 2>/dev/null; COMPREPLY+=($(compgen -f -- "$cur"))
+            ;;
+        'vcs__remote')
+            COMPREPLY+=($(compgen -W 'add remove' -- "$cur"))
+            ;;
+        'vcs__remote__remove')
+            COMPREPLY+=($(compgen -W 'origin upstream' -- "$cur"))
+            ;;
+    esac
+}
+
+complete -F _vcs 'vcs'
@@ -0,0 +1,32 @@
+# fish completion for vcs
+
+complete -c 'vcs' -f
+
+function __vcs_dynamic
+    set -l cur (commandline -ct)
+    set -l words (commandline -opc) "$cur"
+    set -l before (string match -r -- '^--[^=]*=' "$cur")
+    for line in ($words[1] __complete (math (count $words) - 1) $words 2>/dev/null)
+        string sub -s (math (string length -- "$before") + 1) -- $line
+    end
+end
+
+complete -c 'vcs' -n 'not __fish_seen_subcommand_from commit remote' -s 'C' -x -a '(__fish_complete_directories)' -d 'Run as if started in DIR'
+complete -c 'vcs' -n 'not __fish_seen_subcommand_from commit remote' -l 'color' -x -a 'always never auto' -d 'When to use colors'
+complete -c 'vcs' -n 'not __fish_seen_subcommand_from commit remote' -s 'v' -l 'verbose' -d 'Say more: about what'\''s happening'
+complete -c 'vcs' -n 'not __fish_seen_subcommand_from commit remote' -a 'commit' -d 'Record changes'
+complete -c 'vcs' -n 'not __fish_seen_subcommand_from commit remote' -a 'remote' -d 'Manage remotes'
+
+complete -c 'vcs' -n '__fish_seen_subcommand_from commit' -s 'm' -l 'message' -x -d 'Use MSG as the commit message'
+complete -c 'vcs' -n '__fish_seen_subcommand_from commit' -s 'F' -l 'file' -r -F -d 'Take the message from FILE'
+complete -c 'vcs' -n '__fish_seen_subcommand_from commit' -l 'fixup' -x -a '(__vcs_dynamic)' -d 'Fix up COMMIT'
+complete -c 'vcs' -n '__fish_seen_subcommand_from commit' -s 'S' -l 'gpg-sign' -d 'Sign the commit'
+complete -c 'vcs' -n '__fish_seen_subcommand_from commit' -l 'cleanup' -f -a 'strip whitespace verbatim' -d 'Clean up the message'
+complete -c 'vcs' -n '__fish_seen_subcommand_from commit' -F
+
+complete -c 'vcs' -n '__fish_seen_subcommand_from remote; and not __fish_seen_subcommand_from add remove' -a 'add' -d 'Add a remote'
+complete -c 'vcs' -n '__fish_seen_subcommand_from remote; and not __fish_seen_subcommand_from add remove' -a 'remove' -d 'Remove a remote'
+
+complete -c 'vcs' -n '__fish_seen_subcommand_from remote; and __fish_seen_subcommand_from add' -s 'f' -l 'fetch' -d 'Fetch after adding'
+
+complete -c 'vcs' -n '__fish_seen_subcommand_from remote; and __fish_seen_subcommand_from remove' -a 'origin upstream'
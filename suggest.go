@@ -0,0 +1,97 @@
+package lexopt
+
+import (
+	"slices"
+	"strings"
+)
+
+// Find the candidates that look like a misspelling of name, best first.
+//
+// A candidate matches if name is a prefix of it, as in --verb for
+// --verbose, or if it's within a few edits of name, counting an insertion,
+// deletion, substitution or swap of adjacent characters as one edit
+// (the restricted Damerau-Levenshtein distance). Prefixes rank first, then the fewer edits
+// the better. Leading dashes and case are ignored, but candidates are
+// returned as they are.
+//
+// # Example
+//
+//	lexopt.Suggest("--verbsoe", []string{"--version", "--verbose", "--quiet"})
+//	// []string{"--verbose"}
+func Suggest(name string, candidates []string) []string {
+	bare := []rune(strings.ToLower(strings.TrimLeft(name, "-")))
+	if len(bare) == 0 {
+		return nil
+	}
+	maxDistance := max(1, len(bare)/3)
+	type match struct {
+		candidate string
+		score     int
+	}
+	var matches []match
+	for _, candidate := range candidates {
+		other := []rune(strings.ToLower(strings.TrimLeft(candidate, "-")))
+		if slices.Equal(bare, other) {
+			continue
+		}
+		if len(bare) >= 2 && len(other) > len(bare) && slices.Equal(bare, other[:len(bare)]) {
+			matches = append(matches, match{candidate, 0})
+		} else if d := editDistance(bare, other); d <= maxDistance {
+			matches = append(matches, match{candidate, d})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b match) int { return a.score - b.score })
+	var suggestions []string
+	for _, m := range matches {
+		suggestions = append(suggestions, m.candidate)
+	}
+	return suggestions
+}
+
+// The optimal string alignment distance between a and b: the
+// Damerau-Levenshtein distance, where no substring is edited twice.
+func editDistance(a, b []rune) int {
+	// Three rows of the usual dynamic programming table.
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+// Render suggestions as "did you mean '--verbose'?", or "" if there are
+// none. At most three are shown.
+func didYouMean(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return "did you mean " + quotedList(suggestions[:min(len(suggestions), 3)]) + "?"
+}
+
+// Render items as "'a', 'b' or 'c'".
+func quotedList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = "'" + item + "'"
+	}
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1]
+}
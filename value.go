@@ -0,0 +1,142 @@
+package lexopt
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Get a value for an option and parse it into a T.
+//
+// This is parser.Value() followed by ParseValue[T](). Every integer, float
+// and bool kind is supported, as are strings, time.Duration, and types
+// whose pointer implements encoding.TextUnmarshaler or flag.Value. A
+// pointer T is allocated and parsed into.
+//
+// # Errors
+//
+// ErrorMissingValue is returned if the end of the command line is reached.
+// ErrorParsingFailed is returned if the value can't be parsed. It records
+// the option the value belongs to.
+//
+// # Example
+//
+//	if (arg == Short{'n'}) || (arg == Long{"number"}) {
+//	    number, err := lexopt.ValueAs[uint32](parser)
+//	    if err != nil {
+//	        return err
+//	    }
+//	}
+func ValueAs[T any](p *Parser) (T, Error) {
+	return ParseWith(p, ParseValue[T])
+}
+
+// Get a value for an option and parse it with a custom function.
+//
+// This is like ValueAs(), but the conversion is done by parse. Any error it
+// returns is wrapped in an ErrorParsingFailed.
+//
+// # Example
+//
+//	color, err := lexopt.ParseWith(parser, colorFromStr)
+//	if err != nil {
+//	    return err
+//	}
+func ParseWith[T any](p *Parser, parse func(string) (T, error)) (T, Error) {
+	value, err := p.Value()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	result, err2 := parse(value)
+	if err2 != nil {
+		var zero T
+		return zero, p.ParsingFailed(value, err2)
+	}
+	return result, nil
+}
+
+// Parse a string into a T, the way ValueAs() does.
+//
+// Integers are parsed in base 10. Errors from strconv are unwrapped to
+// strconv.ErrSyntax or strconv.ErrRange, since the value is already part of
+// ErrorParsingFailed's message.
+//
+// This panics if T is not a supported type. That's a programming error, not
+// a problem with the command line.
+func ParseValue[T any](value string) (T, error) {
+	var result T
+	err := parseInto(reflect.ValueOf(&result).Elem(), value)
+	return result, err
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+func parseInto(v reflect.Value, value string) error {
+	// Interfaces take priority over the kind, so that e.g. a named int with
+	// a Set method uses it.
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+	if f, ok := v.Addr().Interface().(flag.Value); ok {
+		return f.Set(value)
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := parseInto(elem.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return unwrapNumError(err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return unwrapNumError(err)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return unwrapNumError(err)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return unwrapNumError(err)
+		}
+		v.SetFloat(f)
+	default:
+		panic(fmt.Sprintf("lexopt: cannot parse a value into %v", v.Type()))
+	}
+	return nil
+}
+
+func unwrapNumError(err error) error {
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return numErr.Err
+	}
+	return err
+}
@@ -11,7 +11,10 @@ var _ iter.Seq[string] = (*ValuesIter)(nil).All
 
 func (v *ValuesIter) Next() (string, bool) {
 	parser := v.parser
-	if v.tookFirst {
+	if parser == nil {
+		// The value was attached with =, so it was the only one.
+		return "", false
+	} else if v.tookFirst {
 		return parser.nextIfNormal()
 	} else if value, hadEqSign, ok := parser.rawOptionalValue(); ok {
 		if hadEqSign {